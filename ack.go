@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// 等待客户端确认的超时时间，超时后重发一次，再次超时视为投递失败
+var ackTimeout = envDuration("ACK_TIMEOUT", 10*time.Second)
+
+// 等待确认的投递
+type pendingAck struct {
+	msg     Message
+	timer   *time.Timer
+	resends int
+}
+
+// 投递失败事件内容
+type DeliveryFailed struct {
+	ID   int64  `json:"id"`
+	User string `json:"user"`
+}
+
+// 投递消息，客户端开启确认时等待 ack，超时重发；返回是否成功入队
+func (u *User) deliverMessage(msg Message) bool {
+	tm := timedMessage{msg: msg, receivedAt: msg.Timestamp}
+	var queued bool
+	if urgentFor(msg, u.Username) {
+		queued = u.enqueueUrgent(tm)
+	} else {
+		queued = u.enqueue(tm)
+	}
+	if !u.acks || ackTimeout <= 0 {
+		return queued
+	}
+	u.ackMu.Lock()
+	defer u.ackMu.Unlock()
+	if _, ok := u.pending[msg.ID]; ok {
+		return queued
+	}
+	p := &pendingAck{msg: msg}
+	p.timer = time.AfterFunc(ackTimeout, func() { u.ackExpired(msg.ID) })
+	u.pending[msg.ID] = p
+	return queued
+}
+
+// 确认超时：首次超时重发，再次超时放弃并通知发送者
+func (u *User) ackExpired(id int64) {
+	u.ackMu.Lock()
+	p, ok := u.pending[id]
+	if !ok {
+		u.ackMu.Unlock()
+		return
+	}
+	if p.resends == 0 {
+		p.resends++
+		p.timer.Reset(ackTimeout)
+		u.ackMu.Unlock()
+		u.enqueue(p.msg)
+		return
+	}
+	delete(u.pending, id)
+	u.ackMu.Unlock()
+
+	log.Printf("消息投递失败: id=%d user=%q", id, u.Username)
+	for _, sender := range userConns(p.msg.From) {
+		sender.enqueue(Event{Type: "delivery_failed", Data: DeliveryFailed{ID: id, User: u.Username}})
+	}
+}
+
+// 处理 ack 帧
+func handleAck(user *User, data []byte) {
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的确认")
+		return
+	}
+	user.ackMu.Lock()
+	if p, ok := user.pending[req.ID]; ok {
+		p.timer.Stop()
+		delete(user.pending, req.ID)
+	}
+	user.ackMu.Unlock()
+	// 任一设备确认即视为送达，已读由 read 帧单独标记
+	confirmDelivery(user, req.ID)
+}
+
+// 断开时停止所有等待中的确认计时器
+func (u *User) stopAcks() {
+	u.ackMu.Lock()
+	defer u.ackMu.Unlock()
+	for id, p := range u.pending {
+		p.timer.Stop()
+		delete(u.pending, id)
+	}
+}
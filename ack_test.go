@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func withAckTimeout(t *testing.T, d time.Duration) {
+	t.Helper()
+	old := ackTimeout
+	ackTimeout = d
+	t.Cleanup(func() { ackTimeout = old })
+}
+
+// 以开启确认的方式连接
+func connectWithAcks(t *testing.T, name string) *testClient {
+	t.Helper()
+	c := dialRaw(t, name)
+	c.send(map[string]interface{}{"username": name, "acks": true})
+	c.event("hello")
+	return c
+}
+
+func TestAckedDeliveryIsNotResent(t *testing.T) {
+	resetState(t)
+	withAckTimeout(t, 30*time.Millisecond)
+	newTestSession("dm", false, "alice", "bob")
+	alice := connect(t, "alice")
+	bob := connectWithAcks(t, "bob")
+
+	alice.send(map[string]string{"to": "dm", "content": "hi"})
+	m := bob.message()
+	bob.send(map[string]interface{}{"type": "ack", "id": m.ID})
+	var ev DeliveredEvent
+	_ = json.Unmarshal(alice.event("delivered"), &ev)
+	if ev.ID != m.ID || ev.User != "bob" {
+		t.Fatalf("delivered %+v", ev)
+	}
+	bob.noEvent("", 100*time.Millisecond) // 聊天消息帧没有 type 字段，确认后不再重发
+	alice.noEvent("delivery_failed", 50*time.Millisecond)
+}
+
+func TestUnackedDeliveryResentThenFailed(t *testing.T) {
+	resetState(t)
+	withAckTimeout(t, 30*time.Millisecond)
+	newTestSession("dm", false, "alice", "bob")
+	alice := connect(t, "alice")
+	bob := connectWithAcks(t, "bob")
+
+	alice.send(map[string]string{"to": "dm", "content": "hi"})
+	first := bob.message()
+	if again := bob.message(); again.ID != first.ID {
+		t.Fatalf("resent %+v, want message %d", again, first.ID)
+	}
+	var failed DeliveryFailed
+	_ = json.Unmarshal(alice.event("delivery_failed"), &failed)
+	if failed.ID != first.ID || failed.User != "bob" {
+		t.Fatalf("delivery_failed %+v", failed)
+	}
+	bob.noEvent("", 100*time.Millisecond) // 只重发一次
+}
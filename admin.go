@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// 校验管理员令牌（请求头 X-Admin-Token 需与环境变量 ADMIN_TOKEN 一致）
+// 未配置 ADMIN_TOKEN 时所有管理接口一律拒绝
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !hasAdminToken(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// 请求是否携带有效的管理员令牌
+func hasAdminToken(r *http.Request) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	got := r.Header.Get("X-Admin-Token")
+	return token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// 判断用户是否为管理员（环境变量 ADMIN_USERS，逗号分隔）
+func isAdminUser(username string) bool {
+	for _, name := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" && name == username {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断连接是否可以执行管理员帧：用户名须在 ADMIN_USERS 中，且连接已通过握手挑战认证；
+// 未配置 AUTH_SECRET 时用户名由客户端自报，不足以授予管理权限
+func (u *User) verifiedAdmin() bool {
+	return u.authenticated && isAdminUser(u.Username)
+}
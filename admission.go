@@ -0,0 +1,44 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 握手准入：同时处理的握手数上限（0 表示不限制），超出时最多 handshakeQueue 个连接排队等待 handshakeWait
+var (
+	handshakeConcurrency = envInt("HANDSHAKE_CONCURRENCY", 64)
+	handshakeQueue       = envInt("HANDSHAKE_QUEUE", 1024)
+	handshakeWait        = envDuration("HANDSHAKE_WAIT", 5*time.Second)
+)
+
+var (
+	handshakeSem     = make(chan struct{}, max(handshakeConcurrency, 0))
+	handshakeWaiting atomic.Int64 // 正在排队的握手数
+)
+
+// 申请处理握手的名额，返回的释放函数可重复调用；排队已满或等待超时返回 false
+func admitHandshake() (func(), bool) {
+	if handshakeConcurrency <= 0 {
+		return func() {}, true
+	}
+	select {
+	case handshakeSem <- struct{}{}:
+	default:
+		if handshakeQueue >= 0 && handshakeWaiting.Add(1) > int64(handshakeQueue) {
+			handshakeWaiting.Add(-1)
+			return nil, false
+		}
+		timer := time.NewTimer(handshakeWait)
+		defer timer.Stop()
+		defer handshakeWaiting.Add(-1)
+		select {
+		case handshakeSem <- struct{}{}:
+		case <-timer.C:
+			return nil, false
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-handshakeSem }) }, true
+}
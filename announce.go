@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 发布系统公告（管理员）：未指定 session_id 时发往所有会话
+func announceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req struct {
+		SessionID string `json:"session_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	content := sanitizeContent(req.Content)
+	if content == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var targets []string
+	if req.SessionID != "" {
+		if _, ok := getSession(req.SessionID); !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		targets = []string{req.SessionID}
+	} else {
+		for _, s := range listSessions() {
+			targets = append(targets, s.ID)
+		}
+	}
+
+	posted := make([]Message, 0, len(targets))
+	for _, id := range targets {
+		posted = append(posted, postSystem(Message{
+			From:     systemSender,
+			To:       id,
+			Content:  content,
+			IsSystem: true,
+			Priority: PriorityHigh,
+		}))
+	}
+	audit(adminActor(r), "announce", req.SessionID, content)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(posted)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// 审计日志条目
+type AuditEntry struct {
+	ID     int64     `json:"id"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+var (
+	auditLog  []AuditEntry
+	auditMu   sync.Mutex
+	auditFile = os.Getenv("AUDIT_FILE") // 审计日志持久化文件（JSON Lines，只追加）
+)
+
+// 管理操作的执行者（请求头 X-Admin-User，缺省为 admin）
+func adminActor(r *http.Request) string {
+	if actor := r.Header.Get("X-Admin-User"); actor != "" {
+		return actor
+	}
+	return "admin"
+}
+
+// 从文件加载审计日志
+func loadAudit() {
+	if auditFile == "" {
+		return
+	}
+	f, err := os.Open(auditFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("读取审计日志失败: %v", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err == nil {
+			auditLog = append(auditLog, e)
+		}
+	}
+}
+
+// 追加一条审计记录
+func audit(actor, action, target, detail string) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	e := AuditEntry{
+		ID:     int64(len(auditLog)) + 1,
+		Time:   time.Now(),
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		Detail: detail,
+	}
+	auditLog = append(auditLog, e)
+
+	if auditFile == "" {
+		return
+	}
+	f, err := os.OpenFile(auditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+		return
+	}
+	defer f.Close()
+	data, _ := json.Marshal(e)
+	_, _ = f.Write(append(data, '\n'))
+}
+
+// 查看审计日志（管理员），按从新到旧分页
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	before, limit, ok := parsePage(r, defaultPageSize, maxPageSize)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res := make([]AuditEntry, 0)
+	auditMu.Lock()
+	for i := len(auditLog) - 1; i >= 0 && len(res) < limit; i-- {
+		if before > 0 && auditLog[i].ID >= before {
+			continue
+		}
+		res = append(res, auditLog[i])
+	}
+	auditMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
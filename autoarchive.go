@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// 会话无新消息超过该时长后自动归档，0 表示关闭
+var autoArchiveAfter = envDuration("AUTO_ARCHIVE_AFTER", 0)
+
+// 定期归档长期不活跃的会话，ctx 取消时退出
+func sweepInactiveSessions(ctx context.Context) {
+	if autoArchiveAfter <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !maintenance.Load() { // 维护期间不自动归档
+				autoArchiveOnce(time.Now())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 执行一次自动归档：只处理有过消息、未归档且最后消息早于阈值的会话
+func autoArchiveOnce(now time.Time) {
+	var archived []string
+	sessMu.Lock()
+	for id, s := range sessions {
+		if s.Archived || s.DeletedAt != nil || s.LastMsg == "" || s.LastTime.IsZero() {
+			continue
+		}
+		if now.Sub(s.LastTime) > autoArchiveAfter {
+			s.Archived = true
+			s.AutoArchived = true
+			archived = append(archived, id)
+		}
+	}
+	sessMu.Unlock()
+
+	for _, id := range archived {
+		broadcastEvent(id, Event{Type: "session_archived", Data: map[string]string{"session_id": id}})
+	}
+	if len(archived) > 0 {
+		log.Printf("自动归档不活跃会话: count=%d", len(archived))
+	}
+}
+
+// 自动归档的会话收到新消息时恢复，手动归档的会话保持只读
+func reviveSession(id string) bool {
+	sessMu.Lock()
+	s, ok := sessions[id]
+	revived := ok && s.Archived && s.AutoArchived
+	if revived {
+		s.Archived = false
+		s.AutoArchived = false
+	}
+	sessMu.Unlock()
+	if revived {
+		broadcastEvent(id, Event{Type: "session_unarchived", Data: map[string]string{"session_id": id}})
+	}
+	return revived
+}
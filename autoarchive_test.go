@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func setAutoArchive(t *testing.T, d time.Duration) {
+	old := autoArchiveAfter
+	autoArchiveAfter = d
+	t.Cleanup(func() { autoArchiveAfter = old })
+}
+
+func TestAutoArchiveInactiveSession(t *testing.T) {
+	resetState(t)
+	setAutoArchive(t, time.Hour)
+	newTestSession("old", true, "alice")
+	newTestSession("empty", true, "alice") // 创建后从未有消息
+	newTestSession("fresh", true, "alice")
+	now := time.Now()
+	touchSession("old", "bye", now.Add(-2*time.Hour))
+	touchSession("fresh", "hi", now.Add(-time.Minute))
+	sessMu.Lock()
+	sessions["empty"].LastTime = now.Add(-2 * time.Hour)
+	sessMu.Unlock()
+	alice := connect(t, "alice")
+
+	autoArchiveOnce(now)
+	var ev map[string]string
+	_ = json.Unmarshal(alice.event("session_archived"), &ev)
+	if ev["session_id"] != "old" {
+		t.Fatalf("archived event for %v", ev)
+	}
+	for id, want := range map[string]bool{"old": true, "empty": false, "fresh": false} {
+		if s, _ := getSession(id); s.Archived != want {
+			t.Errorf("%s archived=%v, want %v", id, s.Archived, want)
+		}
+	}
+}
+
+func TestAutoArchivedSessionRevivesOnMessage(t *testing.T) {
+	resetState(t)
+	setAutoArchive(t, time.Hour)
+	newTestSession("old", true, "alice", "bob")
+	touchSession("old", "bye", time.Now().Add(-2*time.Hour))
+	autoArchiveOnce(time.Now())
+	alice := connect(t, "alice")
+
+	alice.send(map[string]string{"to": "old", "content": "back again"})
+	alice.event("session_unarchived")
+	if got := alice.message(); got.Content != "back again" {
+		t.Fatalf("got %+v", got)
+	}
+	if s, _ := getSession("old"); s.Archived || s.AutoArchived {
+		t.Fatalf("session still archived: %+v", s)
+	}
+}
+
+// 因禁言等校验被拒绝的消息不能恢复自动归档的会话
+func TestRejectedMessageDoesNotRevive(t *testing.T) {
+	resetState(t)
+	setAutoArchive(t, time.Hour)
+	newTestSession("old", true, "alice")
+	touchSession("old", "bye", time.Now().Add(-2*time.Hour))
+	autoArchiveOnce(time.Now())
+	alice := connect(t, "alice")
+	floodMu.Lock()
+	floodStateLocked("alice", time.Now()).mutedUntil = time.Now().Add(time.Minute)
+	floodMu.Unlock()
+
+	alice.send(map[string]string{"to": "old", "content": "spam"})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "muted" {
+		t.Fatalf("got %+v", info)
+	}
+	if s, _ := getSession("old"); !s.Archived {
+		t.Fatal("muted send revived the session")
+	}
+}
+
+func TestManuallyArchivedSessionStaysReadOnly(t *testing.T) {
+	resetState(t)
+	newTestSession("closed", true, "alice")
+	sessMu.Lock()
+	sessions["closed"].Archived = true
+	sessMu.Unlock()
+	alice := connect(t, "alice")
+
+	alice.send(map[string]string{"to": "closed", "content": "hi"})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "session_archived" {
+		t.Fatalf("got %+v", info)
+	}
+}
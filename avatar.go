@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 是否启用头像，关闭后消息、会话与用户均不生成头像字段
+var avatarsEnabled = envBool("AVATARS", true)
+
+var (
+	avatarHosts    = envString("AVATAR_HOSTS", "img.icons8.com") // 允许代理的头像域名，逗号分隔
+	avatarMaxBytes = envInt("AVATAR_MAX_BYTES", 1<<20)
+	avatarCacheTTL = envDuration("AVATAR_CACHE_TTL", time.Hour)
+)
+
+// 缓存的头像数据
+type avatarEntry struct {
+	data        []byte
+	contentType string
+	expires     time.Time
+}
+
+// 头像缓存，按规范化后的地址保存；每条最多 AVATAR_MAX_BYTES，条目数受 AVATAR_CACHE_SIZE 限制
+var avatarCache = newTTLCache[avatarEntry](envInt("AVATAR_CACHE_SIZE", 64))
+
+var errAvatarTooLarge = errors.New("avatar too large")
+
+// 判断域名是否在允许列表中
+func avatarHostAllowed(host string) bool {
+	for _, h := range strings.Split(avatarHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" && strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// 抓取头像的客户端，重定向同样需要通过域名校验
+var avatarClient = &http.Client{
+	Timeout: 5 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if !avatarHostAllowed(req.URL.Hostname()) || len(via) >= 3 {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	},
+}
+
+// 抓取远程头像，超过大小上限或非图片时返回错误
+func fetchAvatar(rawURL string) (avatarEntry, error) {
+	resp, err := avatarClient.Get(rawURL)
+	if err != nil {
+		return avatarEntry{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return avatarEntry{}, errors.New(resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return avatarEntry{}, errors.New("not an image")
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(avatarMaxBytes)+1))
+	if err != nil {
+		return avatarEntry{}, err
+	}
+	if len(data) > avatarMaxBytes {
+		return avatarEntry{}, errAvatarTooLarge
+	}
+	// 按内容判断类型，只代理位图；SVG 等可执行脚本的类型不能从本站返回
+	ctype := http.DetectContentType(data)
+	if !sessionAvatarTypes[ctype] {
+		return avatarEntry{}, errors.New("unsupported image type")
+	}
+	return avatarEntry{data: data, contentType: ctype, expires: time.Now().Add(avatarCacheTTL)}, nil
+}
+
+// 头像代理：经由本站抓取并缓存允许域名下的头像
+func avatarHandler(w http.ResponseWriter, r *http.Request) {
+	if !avatarsEnabled {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rawURL := r.URL.Query().Get("url")
+	u, err := url.Parse(rawURL)
+	if rawURL == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !avatarHostAllowed(u.Hostname()) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	key := cacheKey(u)
+	entry, ok := avatarCache.get(key, time.Now())
+	if !ok {
+		entry, err = fetchAvatar(rawURL)
+		switch {
+		case err == errAvatarTooLarge:
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		avatarCache.set(key, entry, entry.expires)
+	}
+
+	w.Header().Set("Content-Type", entry.contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = w.Write(entry.data)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+// 启动上游图片服务并允许代理其域名
+func avatarUpstream(t *testing.T, ctype, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", ctype)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	hosts, cache := avatarHosts, avatarCache
+	avatarHosts, avatarCache = "127.0.0.1", newTTLCache[avatarEntry](4)
+	t.Cleanup(func() { avatarHosts, avatarCache = hosts, cache })
+	return srv, &hits
+}
+
+func getAvatar(rawURL string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	avatarHandler(w, httptest.NewRequest(http.MethodGet, "/api/avatar?url="+url.QueryEscape(rawURL), nil))
+	return w
+}
+
+func TestAvatarProxyCachesByNormalizedURL(t *testing.T) {
+	srv, hits := avatarUpstream(t, "image/png", pngHeader+"data")
+
+	if w := getAvatar(srv.URL + "/a.png?b=2&a=1"); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("first fetch %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	upper := strings.Replace(srv.URL, "http://", "HTTP://", 1)
+	if w := getAvatar(upper + "/a.png?a=1&b=2#x"); w.Code != http.StatusOK {
+		t.Fatalf("cached fetch %d", w.Code)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("upstream hit %d times, want 1", n)
+	}
+}
+
+func TestAvatarProxyRejectsDisallowedHostAndOversize(t *testing.T) {
+	srv, _ := avatarUpstream(t, "image/png", pngHeader+strings.Repeat("x", 64))
+	if w := getAvatar("http://evil.example/a.png"); w.Code != http.StatusForbidden {
+		t.Fatalf("disallowed host %d", w.Code)
+	}
+	max := avatarMaxBytes
+	avatarMaxBytes = 32
+	t.Cleanup(func() { avatarMaxBytes = max })
+	if w := getAvatar(srv.URL + "/big.png"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversize %d", w.Code)
+	}
+}
+
+func TestAvatarProxyRefusesSVG(t *testing.T) {
+	srv, _ := avatarUpstream(t, "image/svg+xml", `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+	if w := getAvatar(srv.URL + "/a.svg"); w.Code != http.StatusBadGateway {
+		t.Fatalf("svg %d", w.Code)
+	}
+}
+
+func TestTTLCacheBoundsAndExpires(t *testing.T) {
+	c := newTTLCache[int](2)
+	now := time.Now()
+	c.set("a", 1, now.Add(time.Hour))
+	c.set("b", 2, now.Add(time.Hour))
+	c.get("a", now) // a 最近使用，b 被淘汰
+	c.set("c", 3, now.Add(time.Hour))
+	if _, ok := c.get("b", now); ok || c.len() != 2 {
+		t.Fatalf("b not evicted, len %d", c.len())
+	}
+	if v, ok := c.get("a", now); !ok || v != 1 {
+		t.Fatal("recently used entry evicted")
+	}
+	c.set("d", 4, now.Add(time.Second))
+	if _, ok := c.get("d", now.Add(2*time.Second)); ok {
+		t.Fatal("expired entry returned")
+	}
+	if c.len() != 1 {
+		t.Fatalf("expired entry kept, len %d", c.len())
+	}
+}
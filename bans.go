@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// 封禁列表，可通过 BANS_FILE 持久化到 JSON 文件
+type BanList struct {
+	Usernames []string `json:"usernames"`
+	IPs       []string `json:"ips"`
+}
+
+var (
+	bannedUsers = make(map[string]bool)
+	bannedIPs   = make(map[string]bool)
+	banMu       sync.Mutex
+	bansFile    = os.Getenv("BANS_FILE")
+)
+
+// 取请求来源 IP
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// 判断用户名或 IP 是否被封禁
+func isBanned(username, ip string) bool {
+	banMu.Lock()
+	defer banMu.Unlock()
+	return bannedUsers[username] || (ip != "" && bannedIPs[ip])
+}
+
+// 生成封禁列表快照（调用方需持有 banMu）
+func banListLocked() BanList {
+	list := BanList{Usernames: make([]string, 0, len(bannedUsers)), IPs: make([]string, 0, len(bannedIPs))}
+	for name := range bannedUsers {
+		list.Usernames = append(list.Usernames, name)
+	}
+	for ip := range bannedIPs {
+		list.IPs = append(list.IPs, ip)
+	}
+	return list
+}
+
+// 从文件加载封禁列表
+func loadBans() {
+	if bansFile == "" {
+		return
+	}
+	data, err := os.ReadFile(bansFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("读取封禁列表失败: %v", err)
+		}
+		return
+	}
+	var list BanList
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("解析封禁列表失败: %v", err)
+		return
+	}
+	banMu.Lock()
+	defer banMu.Unlock()
+	for _, name := range list.Usernames {
+		bannedUsers[name] = true
+	}
+	for _, ip := range list.IPs {
+		bannedIPs[ip] = true
+	}
+}
+
+// 保存封禁列表到文件（调用方需持有 banMu）
+func saveBansLocked() {
+	if bansFile == "" {
+		return
+	}
+	data, _ := json.Marshal(banListLocked())
+	if err := os.WriteFile(bansFile, data, 0o600); err != nil {
+		log.Printf("保存封禁列表失败: %v", err)
+	}
+}
+
+// 断开被封禁的在线连接
+func disconnectBanned() {
+	userMu.Lock()
+	var targets []*User
+	for _, conns := range users {
+		for u := range conns {
+			if isBanned(u.Username, u.ip) {
+				targets = append(targets, u)
+			}
+		}
+	}
+	userMu.Unlock()
+	for _, u := range targets {
+		_ = u.WS.Close(CloseBanned, "banned")
+	}
+}
+
+// 审计记录中的封禁对象描述
+func banTarget(username, ip string) string {
+	switch {
+	case username != "" && ip != "":
+		return username + "@" + ip
+	case username != "":
+		return username
+	default:
+		return ip
+	}
+}
+
+// 封禁管理（管理员）：GET 查看，POST 添加，DELETE 移除
+func bansHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		IP       string `json:"ip"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Username == "" && req.IP == "") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		req.Username = r.URL.Query().Get("username")
+		req.IP = r.URL.Query().Get("ip")
+		if req.Username == "" && req.IP == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	banMu.Lock()
+	switch r.Method {
+	case http.MethodPost:
+		if req.Username != "" {
+			bannedUsers[req.Username] = true
+		}
+		if req.IP != "" {
+			bannedIPs[req.IP] = true
+		}
+		saveBansLocked()
+	case http.MethodDelete:
+		delete(bannedUsers, req.Username)
+		delete(bannedIPs, req.IP)
+		saveBansLocked()
+	}
+	list := banListLocked()
+	banMu.Unlock()
+
+	switch r.Method {
+	case http.MethodPost:
+		audit(adminActor(r), "ban", banTarget(req.Username, req.IP), "")
+		disconnectBanned()
+	case http.MethodDelete:
+		audit(adminActor(r), "unban", banTarget(req.Username, req.IP), "")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
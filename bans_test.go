@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 清空封禁列表且不写文件，测试结束后恢复
+func resetBans(t *testing.T) {
+	banMu.Lock()
+	users, ips, file := bannedUsers, bannedIPs, bansFile
+	bannedUsers, bannedIPs, bansFile = make(map[string]bool), make(map[string]bool), ""
+	banMu.Unlock()
+	t.Cleanup(func() {
+		banMu.Lock()
+		bannedUsers, bannedIPs, bansFile = users, ips, file
+		banMu.Unlock()
+	})
+}
+
+func banRequest(t *testing.T, method, target, body string) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	bansHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s %s returned %d", method, target, w.Code)
+	}
+}
+
+func TestBanDisconnectsAndRejectsHandshake(t *testing.T) {
+	resetState(t)
+	resetBans(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	bob := connect(t, "bob")
+
+	banRequest(t, http.MethodPost, "/api/admin/bans", `{"username":"bob"}`)
+	if code, _ := closedWith(bob); code != CloseBanned {
+		t.Fatalf("online user closed with %d, want %d", code, CloseBanned)
+	}
+
+	again := dialRaw(t, "bob")
+	again.send(map[string]string{"username": "bob"})
+	if code, reason := closedWith(again); code != CloseBanned || reason != "banned" {
+		t.Fatalf("banned connect closed with %d %q", code, reason)
+	}
+
+	banRequest(t, http.MethodDelete, "/api/admin/bans?username=bob", "")
+	connect(t, "bob")
+}
+
+func TestIPBanRejectsAnyUsername(t *testing.T) {
+	resetState(t)
+	resetBans(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	banRequest(t, http.MethodPost, "/api/admin/bans", `{"ip":"127.0.0.1"}`)
+
+	c := dialRaw(t, "carol")
+	c.send(map[string]string{"username": "carol"})
+	if code, _ := closedWith(c); code != CloseBanned {
+		t.Fatalf("closed with %d, want %d", code, CloseBanned)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// 判断用户能否查看消息：须为会话成员且消息对其可见
+func canView(msg Message, username string) bool {
+	return sessionMembers(msg.To)[username] && visibleTo(msg, username)
+}
+
+// 按 ID 列表获取消息：按请求顺序返回调用者可见的消息，未知 ID 直接跳过
+func messagesByIDHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("user")
+	raw := r.URL.Query().Get("ids")
+	if username == "" || raw == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxPageSize {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ids := make([]int64, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	msgMu.Lock()
+	byID := make(map[int64]Message, len(ids))
+	for _, id := range ids {
+		byID[id] = Message{}
+	}
+	for _, msg := range messages {
+		if _, want := byID[msg.ID]; want && !msg.Deleted {
+			byID[msg.ID] = msg
+		}
+	}
+	msgMu.Unlock()
+
+	res := make([]Message, 0, len(ids))
+	for _, id := range ids {
+		if msg := byID[id]; msg.ID != 0 && canView(msg, username) {
+			res = append(res, msg)
+		}
+	}
+
+	withReadState(res)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// 处理 get_message 帧：按 ID 获取单条消息，用于从通知或链接跳转到指定消息
+func handleGetMessage(user *User, data []byte) {
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil || req.ID == 0 {
+		sendError(user, "bad_frame", "无法解析的消息请求")
+		return
+	}
+	msg, ok := findMessage(req.ID)
+	if !ok {
+		sendError(user, "not_found", "消息不存在")
+		return
+	}
+	if !canView(msg, user.Username) {
+		sendError(user, "forbidden", "无权查看该消息")
+		return
+	}
+	list := []Message{localize(msg, user.lang)}
+	withReadState(list)
+	user.enqueue(Event{Type: "message_detail", Data: list[0]})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"container/list"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 带过期时间与容量上限的缓存：过期条目在读取或写入时清除，超出容量时淘汰最久未使用的条目
+type ttlCache[V any] struct {
+	mu    sync.Mutex
+	max   int
+	items map[string]*list.Element
+	order *list.List // 前端为最近使用
+}
+
+type ttlItem[V any] struct {
+	key     string
+	val     V
+	expires time.Time
+}
+
+func newTTLCache[V any](max int) *ttlCache[V] {
+	return &ttlCache[V]{max: max, items: make(map[string]*list.Element), order: list.New()}
+}
+
+// 读取未过期的条目
+func (c *ttlCache[V]) get(key string, now time.Time) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	it := el.Value.(*ttlItem[V])
+	if now.After(it.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return it.val, true
+}
+
+// 写入条目，并淘汰末尾已过期或超出容量的条目
+func (c *ttlCache[V]) set(key string, val V, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		it := el.Value.(*ttlItem[V])
+		it.val, it.expires = val, expires
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&ttlItem[V]{key: key, val: val, expires: expires})
+	}
+	now := time.Now()
+	for el := c.order.Back(); el != nil; el = c.order.Back() {
+		it := el.Value.(*ttlItem[V])
+		if len(c.items) <= c.max && !now.After(it.expires) {
+			break
+		}
+		c.order.Remove(el)
+		delete(c.items, it.key)
+	}
+}
+
+func (c *ttlCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// 规范化地址作为缓存键：协议与域名小写、去掉默认端口与片段、查询参数排序
+func cacheKey(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	host := strings.ToLower(n.Hostname())
+	if port := n.Port(); port != "" && !(n.Scheme == "http" && port == "80") && !(n.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	n.Host = host
+	n.Fragment, n.RawFragment = "", ""
+	n.User = nil
+	n.RawQuery = n.Query().Encode()
+	return n.String()
+}
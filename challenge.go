@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+var (
+	authSecret   = envString("AUTH_SECRET", "")                      // 握手挑战的共享密钥，为空时不启用
+	challengeTTL = envDuration("AUTH_CHALLENGE_TTL", 30*time.Second) // 挑战码有效期
+)
+
+// 连接建立后下发的挑战
+type Challenge struct {
+	Nonce string `json:"nonce"`
+}
+
+// 已下发且尚未使用的挑战码及其过期时间
+var (
+	pendingNonces = make(map[string]time.Time)
+	nonceMu       sync.Mutex
+)
+
+// 是否要求握手携带挑战应答
+func challengeEnabled() bool {
+	return authSecret != ""
+}
+
+// 生成一次性挑战码
+func issueNonce(now time.Time) (string, bool) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", false
+	}
+	nonce := hex.EncodeToString(b)
+	nonceMu.Lock()
+	for n, exp := range pendingNonces {
+		if now.After(exp) {
+			delete(pendingNonces, n)
+		}
+	}
+	pendingNonces[nonce] = now.Add(challengeTTL)
+	nonceMu.Unlock()
+	return nonce, true
+}
+
+// 取出挑战码，每个挑战码只能使用一次，过期或已使用时返回 false
+func consumeNonce(nonce string, now time.Time) bool {
+	nonceMu.Lock()
+	defer nonceMu.Unlock()
+	exp, ok := pendingNonces[nonce]
+	delete(pendingNonces, nonce)
+	return ok && !now.After(exp)
+}
+
+// 计算挑战应答：HMAC-SHA256(secret, nonce + ":" + username) 的十六进制
+func challengeResponse(nonce, username string) string {
+	mac := hmac.New(sha256.New, []byte(authSecret))
+	mac.Write([]byte(nonce + ":" + username))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 校验挑战应答，无论成功与否挑战码都会作废
+func verifyChallenge(nonce, username, response string, now time.Time) bool {
+	if !consumeNonce(nonce, now) {
+		return false
+	}
+	want := challengeResponse(nonce, username)
+	return hmac.Equal([]byte(want), []byte(response))
+}
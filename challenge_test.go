@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func withAuthSecret(t *testing.T, secret string) {
+	t.Helper()
+	old := authSecret
+	authSecret = secret
+	t.Cleanup(func() { authSecret = old })
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	withAuthSecret(t, "test-secret")
+	now := time.Now()
+
+	nonce, _ := issueNonce(now)
+	if !verifyChallenge(nonce, "alice", challengeResponse(nonce, "alice"), now) {
+		t.Fatal("correct response rejected")
+	}
+	if verifyChallenge(nonce, "alice", challengeResponse(nonce, "alice"), now) {
+		t.Fatal("replayed nonce accepted")
+	}
+
+	nonce, _ = issueNonce(now)
+	if verifyChallenge(nonce, "alice", challengeResponse(nonce, "bob"), now) {
+		t.Fatal("response for another username accepted")
+	}
+	// 失败的尝试同样作废挑战码
+	if verifyChallenge(nonce, "alice", challengeResponse(nonce, "alice"), now) {
+		t.Fatal("nonce reusable after a failed attempt")
+	}
+
+	nonce, _ = issueNonce(now)
+	if verifyChallenge(nonce, "alice", challengeResponse(nonce, "alice"), now.Add(challengeTTL+time.Second)) {
+		t.Fatal("expired nonce accepted")
+	}
+}
+
+// 截获的应答在新连接上无效：每个连接下发不同的挑战码
+func TestChallengeHandshake(t *testing.T) {
+	resetState(t)
+	withAuthSecret(t, "test-secret")
+
+	first := dialRaw(t, "alice")
+	var ch Challenge
+	_ = json.Unmarshal(first.event("challenge"), &ch)
+	resp := challengeResponse(ch.Nonce, "alice")
+	first.send(map[string]string{"username": "alice", "auth": resp})
+	first.event("hello")
+
+	replay := dialRaw(t, "alice")
+	replay.event("challenge")
+	replay.send(map[string]string{"username": "alice", "auth": resp})
+	if code, _ := closedWith(replay); code != CloseAuthFailed {
+		t.Fatalf("replayed response closed with %d, want %d", code, CloseAuthFailed)
+	}
+
+	wrong := dialRaw(t, "bob")
+	wrong.event("challenge")
+	wrong.send(map[string]string{"username": "bob", "auth": "00"})
+	if code, _ := closedWith(wrong); code != CloseAuthFailed {
+		t.Fatalf("wrong response closed with %d, want %d", code, CloseAuthFailed)
+	}
+}
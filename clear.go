@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 删除会话的全部消息并加入存储删除队列，返回被删除的消息 ID
+func clearSessionMessages(sessionID string) map[int64]bool {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	removed := make(map[int64]bool)
+	kept := messages[:0]
+	for _, msg := range messages {
+		if msg.To == sessionID {
+			removed[msg.ID] = true
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	// 清空尾部引用，便于回收被删除消息的内容
+	for i := len(kept); i < len(messages); i++ {
+		messages[i] = Message{}
+	}
+	messages = kept
+	unpersistLocked(removed)
+	return removed
+}
+
+// 清空会话历史（管理员）：POST /api/sessions/clear?session_id=，会话本身保留
+func clearHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := getSession(sessionID); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	removed := clearSessionMessages(sessionID)
+	flushStore()
+	sessMu.Lock()
+	if s, ok := sessions[sessionID]; ok {
+		s.LastMsg = ""
+		s.LastTime = time.Time{}
+		s.Pinned = nil
+	}
+	sessMu.Unlock()
+
+	broadcastEvent(sessionID, Event{Type: "history_cleared", Data: map[string]string{"session_id": sessionID}})
+	audit(adminActor(r), "clear_history", sessionID, strconv.Itoa(len(removed)))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"removed": len(removed)})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WebSocket 关闭码，4000 以上为应用自定义
+const (
+	CloseNormal           = 1000
+	CloseGoingAway        = 1001 // 服务端停机或排空
+	CloseProtocolError    = 1002
+	CloseMessageTooBig    = 1009
+	CloseInternalError    = 1011 // 服务端内部错误
+	CloseBadHandshake     = 4000 // 握手数据非法
+	CloseAuthFailed       = 4001 // 认证失败，客户端不应自动重连
+	CloseRateLimited      = 4002 // 触发限流
+	CloseKicked           = 4003 // 被管理员踢出
+	CloseBanned           = 4004 // 已被封禁
+	CloseIdle             = 4005 // 长时间无活动被回收
+	CloseReplaced         = 4006 // 同一用户在别处建立了新连接
+	CloseAlreadyConnected = 4007 // 用户已在线且策略拒绝重复连接
+)
+
+// 关闭帧原因最长 123 字节（控制帧负载上限 125 字节减去 2 字节关闭码）
+const maxCloseReason = 123
+
+// 各类关闭后建议客户端等待的重连时间，实际值在此基础上叠加同等范围内的随机抖动
+var (
+	reconnectAfterDrain    = envDuration("RECONNECT_AFTER_DRAIN", 5*time.Second)
+	reconnectAfterOverload = envDuration("RECONNECT_AFTER_OVERLOAD", 30*time.Second)
+	reconnectAfterError    = envDuration("RECONNECT_AFTER_ERROR", 2*time.Second)
+)
+
+// 关闭前下发的重连建议
+type ReconnectHint struct {
+	Code       int  `json:"code"`
+	Reconnect  bool `json:"reconnect"`             // 为 false 时客户端不应自动重连
+	RetryAfter int  `json:"retry_after,omitempty"` // 建议等待的毫秒数
+}
+
+// 按关闭码生成重连建议，正常关闭等无需提示的情况返回 false
+func reconnectHint(code int) (ReconnectHint, bool) {
+	var base time.Duration
+	switch code {
+	case CloseGoingAway:
+		base = reconnectAfterDrain
+	case CloseRateLimited:
+		base = reconnectAfterOverload
+	case CloseInternalError:
+		base = reconnectAfterError
+	case CloseAuthFailed, CloseBanned, CloseKicked, CloseReplaced, CloseAlreadyConnected:
+		return ReconnectHint{Code: code, Reconnect: false}, true
+	default:
+		return ReconnectHint{}, false
+	}
+	// 随机抖动，避免大量客户端在同一时刻重连
+	wait := base
+	if base > 0 {
+		wait += time.Duration(rand.Int63n(int64(base)))
+	}
+	return ReconnectHint{Code: code, Reconnect: true, RetryAfter: int(wait / time.Millisecond)}, true
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+// 等待服务端关闭连接，返回关闭码与原因
+func closedWith(c *testClient) (int, string) {
+	for range c.frames {
+	}
+	return c.tr.peer.CloseCode, c.tr.peer.CloseReason
+}
+
+func TestHandshakeCloseCodes(t *testing.T) {
+	resetState(t)
+	cases := []struct {
+		handshake string
+		code      int
+		reason    string
+	}{
+		{`{}`, CloseBadHandshake, "invalid handshake"},
+		{`{"username":"system"}`, CloseBadHandshake, "reserved username"},
+	}
+	for _, tc := range cases {
+		c := dialRaw(t, "client")
+		_ = c.tr.Send(tc.handshake)
+		if code, reason := closedWith(c); code != tc.code || reason != tc.reason {
+			t.Errorf("%s: closed with %d %q, want %d %q", tc.handshake, code, reason, tc.code, tc.reason)
+		}
+	}
+}
+
+// 认证失败使用专门的关闭码，客户端据此不再自动重连
+func TestAuthFailureCloseCode(t *testing.T) {
+	resetState(t)
+	secret := authSecret
+	authSecret = "test-secret"
+	t.Cleanup(func() { authSecret = secret })
+
+	c := dialRaw(t, "alice")
+	c.event("challenge")
+	c.send(map[string]string{"username": "alice", "auth": "wrong"})
+	if code, reason := closedWith(c); code != CloseAuthFailed || reason != "authentication failed" {
+		t.Fatalf("closed with %d %q", code, reason)
+	}
+}
+
+func TestDuplicateConnectionCloseCode(t *testing.T) {
+	resetState(t)
+	policy := dupPolicy
+	dupPolicy = DupReject
+	t.Cleanup(func() { dupPolicy = policy })
+	connect(t, "alice")
+
+	c := dialRaw(t, "alice")
+	c.send(map[string]string{"username": "alice"})
+	if code, reason := closedWith(c); code != CloseAlreadyConnected || reason != "already connected" {
+		t.Fatalf("closed with %d %q", code, reason)
+	}
+}
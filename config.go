@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// 读取整数环境变量，未设置或格式错误时返回默认值
+func envInt(name string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// 读取字符串环境变量，未设置时返回默认值
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// 读取时长环境变量（如 30s、5m），未设置或格式错误时返回默认值
+func envDuration(name string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// 读取布尔环境变量（1/true/yes 等），未设置或格式错误时返回默认值
+func envBool(name string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 单个 IP 新建连接的速率限制：每分钟补充的令牌数与桶容量，速率为 0 时不限制
+var (
+	connRatePerMin = envInt("CONN_RATE_PER_MIN", 0)
+	connBurst      = envInt("CONN_BURST", 10)
+)
+
+// 令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var (
+	connBuckets = make(map[string]*tokenBucket) // 按来源 IP 记录
+	connMu      sync.Mutex
+)
+
+// 判断来源 IP 是否还能新建连接，允许时消耗一个令牌
+func allowConnection(ip string, now time.Time) bool {
+	if connRatePerMin <= 0 || ip == "" {
+		return true
+	}
+	connMu.Lock()
+	defer connMu.Unlock()
+
+	b, ok := connBuckets[ip]
+	if !ok {
+		pruneBucketsLocked(now)
+		b = &tokenBucket{tokens: float64(connBurst), last: now}
+		connBuckets[ip] = b
+	}
+	b.tokens = refill(b, now)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// 按流逝时间补充令牌，不超过桶容量
+func refill(b *tokenBucket, now time.Time) float64 {
+	t := b.tokens + now.Sub(b.last).Minutes()*float64(connRatePerMin)
+	if t > float64(connBurst) {
+		t = float64(connBurst)
+	}
+	return t
+}
+
+// 清理已补满的桶，避免大量来源 IP 让表无限增长（调用方需持有 connMu）
+func pruneBucketsLocked(now time.Time) {
+	if len(connBuckets) < 1024 {
+		return
+	}
+	for ip, b := range connBuckets {
+		if refill(b, now) >= float64(connBurst) {
+			delete(connBuckets, ip)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+// 同一用户重复连接时的处理策略
+const (
+	DupReject  = "reject"  // 拒绝新连接
+	DupReplace = "replace" // 踢下旧连接
+	DupMulti   = "multi"   // 允许多端同时在线
+)
+
+var dupPolicy = envString("DUPLICATE_CONN_POLICY", DupReplace)
+
+// 按重复连接策略注册连接，reject 策略下用户已在线时返回 false
+func registerUser(u *User) bool {
+	userMu.Lock()
+	conns := users[u.Username]
+	var replaced []*User
+	switch {
+	case len(conns) == 0:
+	case dupPolicy == DupReject:
+		userMu.Unlock()
+		return false
+	case dupPolicy != DupMulti:
+		for old := range conns {
+			replaced = append(replaced, old)
+		}
+		conns = nil
+	}
+	if conns == nil {
+		conns = make(map[*User]bool)
+		users[u.Username] = conns
+	}
+	conns[u] = true
+	userMu.Unlock()
+
+	// 旧连接被移出在线表后再关闭，其注销逻辑不会影响新连接
+	for _, old := range replaced {
+		old.replaced.Store(true)
+		_ = old.WS.Close(CloseReplaced, "replaced")
+	}
+	return true
+}
+
+// 注销连接，用户没有其他连接时从在线表移除
+func unregisterUser(u *User) {
+	userMu.Lock()
+	defer userMu.Unlock()
+	conns := users[u.Username]
+	delete(conns, u)
+	if len(conns) == 0 {
+		delete(users, u.Username)
+	}
+}
+
+// 返回用户的全部在线连接
+func userConns(username string) []*User {
+	userMu.Lock()
+	defer userMu.Unlock()
+	res := make([]*User, 0, len(users[username]))
+	for u := range users[username] {
+		res = append(res, u)
+	}
+	return res
+}
+
+// 查找在线用户，多端在线时取最近活跃的连接
+func onlineUser(username string) *User {
+	var best *User
+	for _, u := range userConns(username) {
+		if best == nil || u.lastActive().After(best.lastActive()) {
+			best = u
+		}
+	}
+	return best
+}
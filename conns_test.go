@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestReplacedConnectionDoesNotReleaseResumeToken(t *testing.T) {
+	resetState(t)
+	policy := dupPolicy
+	dupPolicy = DupReplace
+	t.Cleanup(func() { dupPolicy = policy })
+
+	first := connect(t, "alice")
+	var token string
+	resumeMu.Lock()
+	for tok := range resumeTokens {
+		token = tok
+	}
+	resumeMu.Unlock()
+	connect(t, "alice")
+
+	// 旧连接被关闭后，其令牌应被作废而不是进入可用状态
+	for range first.frames {
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resumeMu.Lock()
+		_, live := resumeTokens[token]
+		resumeMu.Unlock()
+		if !live {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("replaced connection's resume token is still usable")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := consumeResumeToken(token, time.Now()); ok {
+		t.Fatal("old token resumed while the user is online")
+	}
+}
+
+// 建立连接但不发送握手，由测试自行完成
+func dialRaw(t *testing.T, name string) *testClient {
+	t.Helper()
+	server, client := newPipeTransport()
+	go serveConn(server, "127.0.0.1")
+	c := &testClient{t: t, name: name, tr: client, frames: make(chan []byte, 256)}
+	go func() {
+		for {
+			data, err := client.Receive()
+			if err != nil {
+				close(c.frames)
+				return
+			}
+			c.frames <- data
+		}
+	}()
+	t.Cleanup(func() { _ = client.Close(CloseNormal, "") })
+	return c
+}
+
+// 挑战应答错误的握手不能消耗他人的恢复令牌
+func TestFailedChallengeKeepsResumeToken(t *testing.T) {
+	resetState(t)
+	secret := authSecret
+	authSecret = "test-secret"
+	t.Cleanup(func() { authSecret = secret })
+	resumeTokens["tok"] = &resumeState{username: "alice", expires: time.Now().Add(time.Minute)}
+
+	bad := dialRaw(t, "mallory")
+	bad.event("challenge")
+	bad.send(map[string]string{"resume_token": "tok", "auth": "bogus"})
+	for range bad.frames {
+	}
+	resumeMu.Lock()
+	_, live := resumeTokens["tok"]
+	resumeMu.Unlock()
+	if !live {
+		t.Fatal("unauthenticated handshake consumed the resume token")
+	}
+
+	alice := dialRaw(t, "alice")
+	var ch Challenge
+	_ = json.Unmarshal(alice.event("challenge"), &ch)
+	alice.send(map[string]string{"resume_token": "tok", "auth": challengeResponse(ch.Nonce, "alice")})
+	alice.event("resumed")
+	if _, ok := consumeResumeToken("tok", time.Now()); ok {
+		t.Fatal("token still usable after resuming")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 死信原因
+const (
+	DeadAllOffline = "all_offline" // 接收者均不在线
+	DeadQueueFull  = "queue_full"  // 在线接收者的发送队列均已满
+)
+
+// 保留的死信条数上限，超出时丢弃最早的记录
+var deadLetterMax = envInt("DEAD_LETTER_MAX", 1000)
+
+// 未能投递给任何接收者的消息记录（消息本身仍正常存储）
+type DeadLetter struct {
+	MessageID  int64     `json:"message_id"`
+	SessionID  string    `json:"session_id"`
+	From       string    `json:"from"`
+	Reason     string    `json:"reason"`
+	Recipients int       `json:"recipients"` // 应收到消息的人数
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+var (
+	deadLetters []DeadLetter
+	deadMu      sync.Mutex
+)
+
+// 记录死信
+func recordDeadLetter(msg Message, reason string, recipients int) {
+	if deadLetterMax <= 0 {
+		return
+	}
+	log.Printf("消息无人送达: id=%d session=%s reason=%s", msg.ID, msg.To, reason)
+	deadMu.Lock()
+	defer deadMu.Unlock()
+	deadLetters = append(deadLetters, DeadLetter{
+		MessageID:  msg.ID,
+		SessionID:  msg.To,
+		From:       msg.From,
+		Reason:     reason,
+		Recipients: recipients,
+		Timestamp:  time.Now(),
+	})
+	if n := len(deadLetters) - deadLetterMax; n > 0 {
+		deadLetters = append([]DeadLetter(nil), deadLetters[n:]...)
+	}
+}
+
+// 查看死信记录（管理员），从新到旧
+func deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	deadMu.Lock()
+	res := make([]DeadLetter, 0, len(deadLetters))
+	for i := len(deadLetters) - 1; i >= 0; i-- {
+		res = append(res, deadLetters[i])
+	}
+	deadMu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func resetDeadLetters(t *testing.T) {
+	deadMu.Lock()
+	saved := deadLetters
+	deadLetters = nil
+	deadMu.Unlock()
+	t.Cleanup(func() {
+		deadMu.Lock()
+		deadLetters = saved
+		deadMu.Unlock()
+	})
+}
+
+// 私聊对方不在线：记录死信，消息仍然写入存储
+func TestOfflineDMDeadLetteredButStored(t *testing.T) {
+	resetState(t)
+	resetDeadLetters(t)
+	rec := &recordingStore{}
+	store = rec
+	newTestSession("dm", false, "alice", "bob")
+	alice := connect(t, "alice")
+
+	alice.send(map[string]string{"to": "dm", "content": "are you there?"})
+	sent := alice.message()
+
+	deadMu.Lock()
+	list := append([]DeadLetter(nil), deadLetters...)
+	deadMu.Unlock()
+	if len(list) != 1 {
+		t.Fatalf("dead letters %+v", list)
+	}
+	if d := list[0]; d.MessageID != sent.ID || d.Reason != DeadAllOffline || d.From != "alice" || d.Recipients != 1 {
+		t.Fatalf("dead letter %+v", d)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.saves) == 0 || rec.saves[0].ID != sent.ID {
+		t.Fatalf("message not stored: %+v", rec.saves)
+	}
+}
+
+// 接收者在线时不记录死信
+func TestDeliveredDMNotDeadLettered(t *testing.T) {
+	resetState(t)
+	resetDeadLetters(t)
+	newTestSession("dm", false, "alice", "bob")
+	alice := connect(t, "alice")
+	bob := connect(t, "bob")
+
+	alice.send(map[string]string{"to": "dm", "content": "hi"})
+	bob.message()
+	deadMu.Lock()
+	defer deadMu.Unlock()
+	if len(deadLetters) != 0 {
+		t.Fatalf("dead letters %+v", deadLetters)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 同一用户在同一会话内重复发送相同内容的判定窗口，0 表示关闭去重
+var dedupeWindow = envDuration("DEDUPE_WINDOW", 2*time.Second)
+
+// 最近一次发送记录
+type recentSend struct {
+	content string
+	id      int64
+	at      time.Time
+}
+
+var (
+	recentSends = make(map[userSessionKey]recentSend)
+	recentMu    sync.Mutex
+)
+
+// 去重事件内容
+type DedupeEvent struct {
+	SessionID string `json:"session_id"`
+	ID        int64  `json:"id"` // 已存在的消息 ID
+}
+
+// 查找窗口内内容相同的上一条消息，返回其 ID
+func findDuplicate(msg Message, now time.Time) (int64, bool) {
+	if dedupeWindow <= 0 {
+		return 0, false
+	}
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	last, ok := recentSends[userSessionKey{user: msg.From, session: msg.To}]
+	if !ok || last.content != msg.Content || now.Sub(last.at) > dedupeWindow {
+		return 0, false
+	}
+	return last.id, true
+}
+
+// 记录最近一次发送
+func rememberSend(msg Message) {
+	if dedupeWindow <= 0 {
+		return
+	}
+	recentMu.Lock()
+	defer recentMu.Unlock()
+	recentSends[userSessionKey{user: msg.From, session: msg.To}] = recentSend{content: msg.Content, id: msg.ID, at: msg.Timestamp}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 批量删除事件内容
+type DeletedEvent struct {
+	SessionID string  `json:"session_id"`
+	IDs       []int64 `json:"ids"`
+}
+
+// 将用户在指定会话（为空时为全部会话）中发送的消息全部标记为墓碑，返回按会话分组的消息
+func deleteUserMessages(username, sessionID string) map[string][]Message {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	now := time.Now()
+	deleted := make(map[string][]Message)
+	for i := range messages {
+		m := &messages[i]
+		if m.From != username || m.Deleted || (sessionID != "" && m.To != sessionID) {
+			continue
+		}
+		tombstone(m)
+		m.UpdatedAt = now
+		deleted[m.To] = append(deleted[m.To], *m)
+		persistLocked(*m)
+	}
+	return deleted
+}
+
+// 处理 delete_my_messages 帧：只删除调用者本人的消息，并按会话广播删除事件
+func handleDeleteMyMessages(user *User, data []byte) {
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的删除请求")
+		return
+	}
+	if req.SessionID != "" {
+		if _, ok := getSession(req.SessionID); !ok {
+			sendError(user, "unknown_session", "会话不存在")
+			return
+		}
+	}
+
+	total := 0
+	deleted := deleteUserMessages(user.Username, req.SessionID)
+	flushStore()
+	for sid, msgs := range deleted {
+		ids := make([]int64, len(msgs))
+		for i, m := range msgs {
+			ids[i] = m.ID
+		}
+		total += len(ids)
+		broadcastEvent(sid, Event{Type: "messages_deleted", Data: DeletedEvent{SessionID: sid, IDs: ids}})
+	}
+	user.enqueue(Event{Type: "delete_done", Data: map[string]interface{}{"session_id": req.SessionID, "count": total}})
+}
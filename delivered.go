@@ -0,0 +1,57 @@
+package main
+
+import "time"
+
+// 送达事件内容：接收者至少一台设备确认收到
+type DeliveredEvent struct {
+	SessionID string `json:"session_id"`
+	ID        int64  `json:"id"`
+	User      string `json:"user"`
+}
+
+// 判断消息是否已送达指定用户的任一设备
+func deliveredTo(msg Message, username string) bool {
+	for _, name := range msg.DeliveredTo {
+		if name == username {
+			return true
+		}
+	}
+	return false
+}
+
+// 记录消息已送达用户，与已读状态互相独立；首次送达时写入存储队列并返回更新后的消息
+func markDelivered(username string, id int64) (Message, bool) {
+	orig, ok := findMessage(id)
+	if !ok || orig.From == username || !visibleTo(orig, username) || !sessionMembers(orig.To)[username] {
+		return Message{}, false
+	}
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	for i, msg := range messages {
+		if msg.ID != id {
+			continue
+		}
+		if msg.Deleted || deliveredTo(msg, username) {
+			return Message{}, false
+		}
+		// 写时复制，避免影响已取出的消息快照
+		messages[i].DeliveredTo = append(append([]string(nil), msg.DeliveredTo...), username)
+		messages[i].UpdatedAt = time.Now()
+		persistLocked(messages[i])
+		return messages[i], true
+	}
+	return Message{}, false
+}
+
+// 设备确认收到消息：首次送达时持久化并通知发送者的所有连接
+func confirmDelivery(user *User, id int64) {
+	msg, ok := markDelivered(user.Username, id)
+	if !ok {
+		return
+	}
+	flushStore()
+	ev := Event{Type: "delivered", Data: DeliveredEvent{SessionID: msg.To, ID: msg.ID, User: user.Username}}
+	for _, sender := range userConns(msg.From) {
+		sender.enqueue(ev)
+	}
+}
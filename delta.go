@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 解析时间参数，支持 RFC3339 与 Unix 毫秒时间戳
+func parseTimeParam(v string) (time.Time, bool) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), true
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	return t, err == nil
+}
+
+// 解析 updated_since 参数，未提供时返回零值
+func parseUpdatedSince(r *http.Request) (since time.Time, set, ok bool) {
+	v := r.URL.Query().Get("updated_since")
+	if v == "" {
+		return time.Time{}, false, true
+	}
+	since, ok = parseTimeParam(v)
+	return since, true, ok
+}
+
+// 解析 from / to 时间范围（闭区间），任一端可省略，from 晚于 to 时视为非法
+func parseTimeRange(r *http.Request) (from, to time.Time, ok bool) {
+	q := r.URL.Query()
+	if v := q.Get("from"); v != "" {
+		if from, ok = parseTimeParam(v); !ok {
+			return from, to, false
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, ok = parseTimeParam(v); !ok {
+			return from, to, false
+		}
+	}
+	return from, to, from.IsZero() || to.IsZero() || !from.After(to)
+}
+
+// 判断时间是否落在范围内，零值端表示不限
+func inTimeRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
+
+// 将消息标记为已删除：保留墓碑供增量同步，清空内容
+func tombstone(m *Message) {
+	m.Deleted = true
+	m.Content = ""
+	m.Quoted = nil
+	m.Quotes = nil
+	m.Forwarded = nil
+	m.Preview = nil
+	m.Meta = nil
+	m.Streaming = false
+}
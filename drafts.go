@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+var (
+	drafts  = make(map[userSessionKey]string) // 用户在各会话的未发送草稿
+	draftMu sync.Mutex
+)
+
+// 草稿内容
+type Draft struct {
+	SessionID string `json:"session_id"`
+	Content   string `json:"content"`
+}
+
+// 保存草稿，内容为空时视为清除
+func saveDraft(username, sessionID, content string) {
+	draftMu.Lock()
+	defer draftMu.Unlock()
+	key := userSessionKey{user: username, session: sessionID}
+	if content == "" {
+		delete(drafts, key)
+		return
+	}
+	drafts[key] = content
+}
+
+// 获取草稿
+func getDraft(username, sessionID string) string {
+	draftMu.Lock()
+	defer draftMu.Unlock()
+	return drafts[userSessionKey{user: username, session: sessionID}]
+}
+
+// 返回用户的全部草稿
+func userDrafts(username string) []Draft {
+	draftMu.Lock()
+	defer draftMu.Unlock()
+	res := make([]Draft, 0)
+	for key, content := range drafts {
+		if key.user == username {
+			res = append(res, Draft{SessionID: key.session, Content: content})
+		}
+	}
+	return res
+}
+
+// 处理 save_draft / clear_draft 帧，草稿仅保存在服务端，不会广播
+func handleDraft(user *User, data []byte, clear bool) {
+	var req Draft
+	if err := json.Unmarshal(data, &req); err != nil || req.SessionID == "" {
+		sendError(user, "bad_frame", "无法解析的草稿")
+		return
+	}
+	if clear {
+		req.Content = ""
+	}
+	saveDraft(user.Username, req.SessionID, req.Content)
+}
+
+// 获取指定会话的草稿
+func draftHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	username := r.URL.Query().Get("user")
+	if sessionID == "" || username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(Draft{SessionID: sessionID, Content: getDraft(username, sessionID)})
+}
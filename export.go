@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// 导出会话历史：GET /api/export?session_id=&user=[&format=ndjson][&after=]
+// 默认返回 JSON 数组；ndjson 模式逐行流式输出，可用 after 从已导出的最后一条 ID 之后续传
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sessionID := q.Get("session_id")
+	username := q.Get("user")
+	if sessionID == "" || username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := getSession(sessionID); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !sessionMembers(sessionID)[username] {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var after int64
+	if v := q.Get("after"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		after = id
+	}
+
+	if q.Get("format") != "ndjson" {
+		list := make([]Message, 0)
+		for more := true; more; {
+			var chunk []Message
+			chunk, after, more = exportChunk(sessionID, username, after)
+			list = append(list, chunk...)
+		}
+		withReadState(list)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	// 逐块取出并写出，块之间释放锁，每块写完即刷新让客户端边收边写
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for more := true; more; {
+		var chunk []Message
+		chunk, after, more = exportChunk(sessionID, username, after)
+		withReadState(chunk)
+		for _, msg := range chunk {
+			if err := enc.Encode(msg); err != nil {
+				return
+			}
+		}
+		if flusher != nil && len(chunk) > 0 {
+			flusher.Flush()
+		}
+	}
+}
+
+// 导出时每次持锁最多扫描的消息数
+var exportChunkSize = envInt("EXPORT_CHUNK_SIZE", 500)
+
+// 从 ID 大于 after 处起扫描一块消息，返回其中属于会话且对用户可见的未删除消息（按 ID 升序）、
+// 下一块的起始游标以及是否还有剩余
+func exportChunk(sessionID, viewer string, after int64) ([]Message, int64, bool) {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	start := sort.Search(len(messages), func(i int) bool { return messages[i].ID > after })
+	end := len(messages)
+	if exportChunkSize > 0 && start+exportChunkSize < end {
+		end = start + exportChunkSize
+	}
+	var res []Message
+	for _, msg := range messages[start:end] {
+		if msg.To == sessionID && !msg.Deleted && visibleTo(msg, viewer) {
+			res = append(res, msg)
+		}
+	}
+	if end == start {
+		return res, after, false
+	}
+	return res, messages[end-1].ID, end < len(messages)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 记录刷新次数，并确认刷新时没有持有 msgMu
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	t       *testing.T
+	flushes int
+}
+
+func (f *flushRecorder) Flush() {
+	if !msgMu.TryLock() {
+		f.t.Error("msgMu held while flushing an export chunk")
+	} else {
+		msgMu.Unlock()
+	}
+	f.flushes++
+	f.ResponseRecorder.Flush()
+}
+
+func TestExportNDJSONStreamsInChunks(t *testing.T) {
+	resetState(t)
+	size := exportChunkSize
+	exportChunkSize = 2
+	t.Cleanup(func() { exportChunkSize = size })
+	newTestSession("room", true, "alice")
+	newTestSession("other", true, "alice")
+	for i := 0; i < 5; i++ {
+		seedMessage(Message{From: "alice", To: "room", Content: "m"})
+		seedMessage(Message{From: "alice", To: "other", Content: "x"})
+	}
+
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), t: t}
+	exportHandler(w, httptest.NewRequest(http.MethodGet, "/api/export?session_id=room&user=alice&format=ndjson&after=2", nil))
+
+	var ids []int64
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var m Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("bad line %q", sc.Text())
+		}
+		if m.To != "room" {
+			t.Fatalf("exported message from %q", m.To)
+		}
+		ids = append(ids, m.ID)
+	}
+	if len(ids) != 4 || ids[0] != 3 || ids[3] != 9 {
+		t.Fatalf("exported ids %v", ids)
+	}
+	if w.flushes < 4 {
+		t.Fatalf("flushed %d times, want one per non-empty chunk", w.flushes)
+	}
+}
+
+// 不带 after 的完整导出：每行都能解析，会话内消息一条不缺且按 ID 升序
+func TestExportNDJSONFull(t *testing.T) {
+	resetState(t)
+	size := exportChunkSize
+	exportChunkSize = 3
+	t.Cleanup(func() { exportChunkSize = size })
+	newTestSession("room", true, "alice")
+	newTestSession("other", true, "alice")
+	var want []int64
+	for i := 0; i < 7; i++ {
+		want = append(want, seedMessage(Message{From: "alice", To: "room", Content: "m"}).ID)
+		seedMessage(Message{From: "alice", To: "other", Content: "x"})
+	}
+
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), t: t}
+	exportHandler(w, httptest.NewRequest(http.MethodGet, "/api/export?session_id=room&user=alice&format=ndjson", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export returned %d", w.Code)
+	}
+
+	var got []int64
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		var m Message
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("line %d does not parse: %q", len(got)+1, sc.Text())
+		}
+		got = append(got, m.ID)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("exported %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("exported %v, want %v", got, want)
+		}
+	}
+}
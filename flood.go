@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// 刷屏防护：每个用户每分钟可发送的消息数与突发容量（速率为 0 时不限制）；
+// floodWindow 内触发限流 floodStrikes 次即自动禁言，时长从 floodMuteBase 起逐次翻倍，最长 floodMuteMax，
+// 解除禁言后 floodDecay 内未再被禁言则恢复到初始时长
+var (
+	msgRatePerMin = envInt("MSG_RATE_PER_MIN", 0)
+	msgBurst      = envInt("MSG_BURST", 10)
+	floodStrikes  = envInt("FLOOD_STRIKES", 3)
+	floodWindow   = envDuration("FLOOD_WINDOW", time.Minute)
+	floodMuteBase = envDuration("FLOOD_MUTE_BASE", time.Minute)
+	floodMuteMax  = envDuration("FLOOD_MUTE_MAX", time.Hour)
+	floodDecay    = envDuration("FLOOD_DECAY", time.Hour)
+)
+
+// 用户的刷屏状态
+type floodState struct {
+	bucket     tokenBucket
+	strikes    []time.Time // 窗口内的限流记录
+	level      int         // 已升级的次数，决定下次禁言时长
+	mutedUntil time.Time
+}
+
+var (
+	floodStates = make(map[string]*floodState)
+	floodMu     sync.Mutex
+)
+
+// 自动禁言事件内容
+type MutedEvent struct {
+	Until time.Time `json:"until"`
+}
+
+func floodStateLocked(username string, now time.Time) *floodState {
+	st, ok := floodStates[username]
+	if !ok {
+		st = &floodState{bucket: tokenBucket{tokens: float64(msgBurst), last: now}}
+		floodStates[username] = st
+	}
+	return st
+}
+
+// 自动禁言剩余秒数（向上取整），0 表示未被禁言
+func muteRemaining(username string, now time.Time) int {
+	floodMu.Lock()
+	defer floodMu.Unlock()
+	st, ok := floodStates[username]
+	if !ok || !now.Before(st.mutedUntil) {
+		return 0
+	}
+	return int(math.Ceil(st.mutedUntil.Sub(now).Seconds()))
+}
+
+// 判断用户是否还能发送消息，允许时消耗一个令牌
+func allowMessage(username string, now time.Time) bool {
+	if msgRatePerMin <= 0 {
+		return true
+	}
+	floodMu.Lock()
+	defer floodMu.Unlock()
+	b := &floodStateLocked(username, now).bucket
+	b.tokens = math.Min(b.tokens+now.Sub(b.last).Minutes()*float64(msgRatePerMin), float64(msgBurst))
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// 记录一次触发限流，窗口内次数达到阈值时按升级后的时长禁言并返回解除时间
+func recordViolation(username string, now time.Time) (time.Time, bool) {
+	if floodStrikes <= 0 {
+		return time.Time{}, false
+	}
+	floodMu.Lock()
+	defer floodMu.Unlock()
+	st := floodStateLocked(username, now)
+	kept := st.strikes[:0]
+	for _, t := range st.strikes {
+		if now.Sub(t) <= floodWindow {
+			kept = append(kept, t)
+		}
+	}
+	st.strikes = append(kept, now)
+	if len(st.strikes) < floodStrikes {
+		return time.Time{}, false
+	}
+
+	// 上次禁言结束后足够久没有再犯，时长回到初始值
+	if !st.mutedUntil.IsZero() && now.Sub(st.mutedUntil) > floodDecay {
+		st.level = 0
+	}
+	d := floodMuteBase << st.level
+	if d > floodMuteMax || d <= 0 {
+		d = floodMuteMax
+	} else {
+		st.level++
+	}
+	st.strikes = nil
+	st.mutedUntil = now.Add(d)
+	return st.mutedUntil, true
+}
+
+// 触发限流时记录违规并告知用户，升级为禁言时推送 muted 事件
+func floodViolation(user *User, now time.Time) {
+	if until, muted := recordViolation(user.Username, now); muted {
+		user.enqueue(Event{Type: "muted", Data: MutedEvent{Until: until}})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 设置刷屏防护参数，测试结束后恢复
+func withFloodConfig(t *testing.T, rate, burst, strikes int, window, base, max, decay time.Duration) {
+	t.Helper()
+	r, b, s, w, mb, mm, d := msgRatePerMin, msgBurst, floodStrikes, floodWindow, floodMuteBase, floodMuteMax, floodDecay
+	msgRatePerMin, msgBurst, floodStrikes, floodWindow, floodMuteBase, floodMuteMax, floodDecay = rate, burst, strikes, window, base, max, decay
+	t.Cleanup(func() {
+		msgRatePerMin, msgBurst, floodStrikes, floodWindow, floodMuteBase, floodMuteMax, floodDecay = r, b, s, w, mb, mm, d
+	})
+}
+
+// 连续触发 n 次限流，返回最后一次的结果
+func strike(n int, at time.Time) (time.Time, bool) {
+	var until time.Time
+	var muted bool
+	for i := 0; i < n; i++ {
+		until, muted = recordViolation("alice", at)
+	}
+	return until, muted
+}
+
+func TestFloodMuteEscalatesAndExpires(t *testing.T) {
+	resetState(t)
+	withFloodConfig(t, 0, 10, 3, time.Minute, time.Minute, 4*time.Minute, time.Hour)
+	now := time.Now()
+
+	if _, muted := strike(2, now); muted {
+		t.Fatal("muted before reaching the strike threshold")
+	}
+	until, muted := strike(1, now)
+	if !muted || !until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("third strike: muted=%v until=%v", muted, until)
+	}
+	if got := muteRemaining("alice", now.Add(30*time.Second)); got != 30 {
+		t.Fatalf("remaining %d, want 30", got)
+	}
+	// 禁言到期自动解除
+	if got := muteRemaining("alice", until); got != 0 {
+		t.Fatalf("still muted at expiry: %d", got)
+	}
+
+	// 再犯时长逐次翻倍，达到上限后不再增长
+	for _, want := range []time.Duration{2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		now = until.Add(time.Second)
+		if until, muted = strike(3, now); !muted || until.Sub(now) != want {
+			t.Fatalf("escalated mute %v, want %v", until.Sub(now), want)
+		}
+	}
+
+	// 解除后足够久未再犯，恢复初始时长
+	now = until.Add(2 * time.Hour)
+	if until, _ = strike(3, now); until.Sub(now) != time.Minute {
+		t.Fatalf("after decay mute %v, want 1m", until.Sub(now))
+	}
+}
+
+// 窗口外的限流记录不计入
+func TestFloodStrikesOutsideWindowIgnored(t *testing.T) {
+	resetState(t)
+	withFloodConfig(t, 0, 10, 3, time.Minute, time.Minute, time.Hour, time.Hour)
+	now := time.Now()
+	strike(2, now)
+	if _, muted := strike(1, now.Add(2*time.Minute)); muted {
+		t.Fatal("stale strikes counted toward the mute")
+	}
+}
+
+// 反复触发限流后收到 muted 事件，禁言期间发送返回剩余秒数
+func TestRepeatedRateLimitAutoMutes(t *testing.T) {
+	resetState(t)
+	withFloodConfig(t, 1, 1, 2, time.Minute, time.Minute, time.Hour, time.Hour)
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+
+	send := func(i int) { alice.send(map[string]string{"to": "room", "content": fmt.Sprintf("spam %d", i)}) }
+	send(0)
+	alice.message()
+	send(1)
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "rate_limited" {
+		t.Fatalf("second send got %+v", info)
+	}
+	send(2)
+	var ev MutedEvent
+	_ = json.Unmarshal(alice.event("muted"), &ev)
+	if time.Until(ev.Until) <= 0 {
+		t.Fatalf("muted until %v", ev.Until)
+	}
+	send(3)
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "muted" || info.RetryAfter <= 0 || info.RetryAfter > 60 {
+		t.Fatalf("send while muted got %+v", info)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 单条消息的格式限制，0 表示不限制
+var (
+	maxLines    = envInt("MAX_LINES", 50)
+	maxMentions = envInt("MAX_MENTIONS", 10)
+)
+
+// 统计 @ 提及次数：@ 后紧跟非空白字符才算一次
+func countMentions(content string) int {
+	n := 0
+	for i := strings.IndexByte(content, '@'); i >= 0; i = strings.IndexByte(content, '@') {
+		content = content[i+1:]
+		if r, _ := utf8.DecodeRuneInString(content); content != "" && !unicode.IsSpace(r) && r != '@' {
+			n++
+		}
+	}
+	return n
+}
+
+// 检查行数与提及数，超出时返回错误码与提示
+func checkFormatting(content string) (code, text string) {
+	if maxLines > 0 && strings.Count(content, "\n")+1 > maxLines {
+		return "too_many_lines", "消息行数过多"
+	}
+	if maxMentions > 0 && countMentions(content) > maxMentions {
+		return "too_many_mentions", "单条消息 @ 的人数过多"
+	}
+	return "", ""
+}
@@ -0,0 +1,76 @@
+package main
+
+import "encoding/json"
+
+// 同一原始消息再次转发到已包含它的会话时，直接返回已有消息而不重复存储
+var forwardDedupe = envBool("FORWARD_DEDUPE", true)
+
+// 转发来源信息，Context 为被转发消息所回复的原消息片段
+type ForwardInfo struct {
+	ID        int64          `json:"id"`
+	From      string         `json:"from"`
+	SessionID string         `json:"session_id"`
+	Context   *QuotedSnippet `json:"context,omitempty"`
+
+	// 转发链最初的原始消息，多次转发时保持不变
+	OriginID   int64  `json:"origin_id,omitempty"`
+	OriginFrom string `json:"origin_from,omitempty"`
+}
+
+// 取消息在转发链上的原始消息 ID 与发送者
+func forwardOrigin(msg Message) (int64, string) {
+	if f := msg.Forwarded; f != nil {
+		if f.OriginID != 0 {
+			return f.OriginID, f.OriginFrom
+		}
+		return f.ID, f.From // 兼容记录原始来源之前的转发
+	}
+	return msg.ID, msg.From
+}
+
+// 查找会话中已有的同源消息：原始消息本身或其转发，悄悄话不计入
+func findForwarded(sessionID string, originID int64) (int64, bool) {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	for _, msg := range messages {
+		if msg.To != sessionID || msg.Deleted || len(msg.Whisper) > 0 {
+			continue
+		}
+		if id, _ := forwardOrigin(msg); id == originID {
+			return msg.ID, true
+		}
+	}
+	return 0, false
+}
+
+// 处理 forward 帧：把可见的消息转发到另一会话，with_context 时附带其回复的原消息片段
+func handleForward(user *User, data []byte) {
+	var req struct {
+		ID          int64  `json:"id"`
+		To          string `json:"to"`
+		WithContext bool   `json:"with_context"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的转发请求")
+		return
+	}
+	orig, ok := findMessage(req.ID)
+	if !ok || !canView(orig, user.Username) {
+		sendError(user, "not_found", "消息不存在")
+		return
+	}
+
+	originID, originFrom := forwardOrigin(orig)
+	if forwardDedupe {
+		if id, ok := findForwarded(req.To, originID); ok && ensureMember(req.To, user.Username) {
+			user.enqueue(Event{Type: "deduplicated", Data: DedupeEvent{SessionID: req.To, ID: id}})
+			return
+		}
+	}
+
+	fwd := &ForwardInfo{ID: orig.ID, From: orig.From, SessionID: orig.To, OriginID: originID, OriginFrom: originFrom}
+	if req.WithContext && orig.ReplyTo != 0 {
+		fwd.Context = quoteSnippet(orig.To, orig.ReplyTo, user.Username)
+	}
+	handleMessage(user, Message{To: req.To, Content: orig.Content, Forwarded: fwd})
+}
@@ -0,0 +1,83 @@
+package main
+
+import "encoding/json"
+
+// 客户端帧公共头，type 为空时视为聊天消息
+type frameHeader struct {
+	Type string `json:"type"`
+}
+
+// 按类型分发客户端帧
+func handleFrame(user *User, data []byte) {
+	var head frameHeader
+	if err := json.Unmarshal(data, &head); err != nil {
+		sendError(user, "bad_frame", "无法解析的数据帧")
+		return
+	}
+
+	// 维护期间保持连接，但拒绝写操作
+	if maintenance.Load() && writeFrames[head.Type] {
+		sendError(user, "maintenance", "服务维护中，暂时无法发送")
+		return
+	}
+
+	switch head.Type {
+	case "", "message":
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			sendError(user, "bad_frame", "无法解析的消息")
+			return
+		}
+		msg.Whisper = nil   // 悄悄话只能通过 whisper 帧发送
+		msg.Forwarded = nil // 转发信息只能通过 forward 帧生成
+		handleMessage(user, msg)
+	case "whisper":
+		handleWhisper(user, data)
+	case "forward":
+		handleForward(user, data)
+	case "move":
+		handleMove(user, data)
+	case "translate":
+		handleTranslate(user, data)
+	case "set_prefs":
+		handleSetPrefs(user, data)
+	case "snooze":
+		handleSnooze(user, data)
+	case "set_presence":
+		handleSetPresence(user, data)
+	case "get_online":
+		handleGetOnline(user, data)
+	case "typing":
+		handleTyping(user, data)
+	case "join":
+		handleJoin(user, data)
+	case "ack":
+		handleAck(user, data)
+	case "read":
+		handleRead(user, data)
+	case "get_badge":
+		handleGetBadge(user)
+	case "get_message":
+		handleGetMessage(user, data)
+	case "append":
+		handleAppend(user, data, false)
+	case "complete":
+		handleAppend(user, data, true)
+	case "save_draft":
+		handleDraft(user, data, false)
+	case "clear_draft":
+		handleDraft(user, data, true)
+	case "reorder_sessions":
+		handleReorderSessions(user, data)
+	case "delete_my_messages":
+		handleDeleteMyMessages(user, data)
+	case "report":
+		handleReport(user, data)
+	case "pin":
+		handlePin(user, data, true)
+	case "unpin":
+		handlePin(user, data, false)
+	default:
+		sendError(user, "unknown_type", "不支持的帧类型")
+	}
+}
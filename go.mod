@@ -1,2 +1,5 @@
 module tg-chat
-go 1.21
+
+go 1.26.0
+
+require golang.org/x/net v0.59.0
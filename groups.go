@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 单个用户最多可加入的会话数，0 表示不限制；管理员不受限
+var maxSessionsPerUser = envInt("MAX_SESSIONS_PER_USER", 100)
+
+// 会话 ID 序号（由 sessMu 保护）
+var sessionSeq int64
+
+// 开启后同名群组只会创建一次，重复创建返回已有会话
+var dedupeSessionNames = envBool("SESSION_NAME_DEDUPE", false)
+
+// 按名称查找未删除的会话（调用方需持有 sessMu）
+func findSessionByNameLocked(name string) (*Session, bool) {
+	for _, id := range sessionOrder {
+		if s := sessions[id]; s.DeletedAt == nil && strings.EqualFold(s.Name, name) {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// 统计用户已加入的会话数（调用方需持有 sessMu）
+func memberCountLocked(username string) int {
+	n := 0
+	for _, m := range members {
+		if m[username] {
+			n++
+		}
+	}
+	return n
+}
+
+// 判断用户是否已达会话数上限（调用方需持有 sessMu）
+func atSessionLimitLocked(username string) bool {
+	if maxSessionsPerUser <= 0 || isAdminUser(username) {
+		return false
+	}
+	return memberCountLocked(username) >= maxSessionsPerUser
+}
+
+// 创建群组会话，创建者自动成为成员
+func createSessionHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("user")
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Name   string `json:"name"`
+		Avatar string `json:"avatar"`
+		Public bool   `json:"public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// 查重、分配 ID 与写入在同一把锁内完成，并发创建不会产生重复会话
+	sessMu.Lock()
+	if dedupeSessionNames {
+		if existing, ok := findSessionByNameLocked(req.Name); ok {
+			// 重名时加入已有的公开会话；私有会话不能借重名加入，也不透露其信息
+			switch {
+			case members[existing.ID][username]:
+			case !existing.Public:
+				sessMu.Unlock()
+				http.Error(w, "同名会话已存在", http.StatusConflict)
+				return
+			case atSessionLimitLocked(username):
+				sessMu.Unlock()
+				http.Error(w, "已达会话数量上限", http.StatusForbidden)
+				return
+			default:
+				addMemberLocked(existing.ID, username)
+			}
+			res := *existing
+			sessMu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	if atSessionLimitLocked(username) {
+		sessMu.Unlock()
+		http.Error(w, "已达会话数量上限", http.StatusForbidden)
+		return
+	}
+	sessionSeq++
+	s := &Session{
+		ID:       fmt.Sprintf("group-%d", sessionSeq),
+		Name:     req.Name,
+		Avatar:   req.Avatar,
+		IsGroup:  true,
+		Public:   req.Public,
+		LastTime: time.Now(),
+		Owner:    username,
+	}
+	if !avatarsEnabled {
+		s.Avatar = ""
+	}
+	sessions[s.ID] = s
+	sessionOrder = append(sessionOrder, s.ID)
+	addMemberLocked(s.ID, username)
+	res := *s
+	sessMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// 处理 join 帧：加入公开会话
+func handleJoin(user *User, data []byte) {
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的加入请求")
+		return
+	}
+
+	sessMu.Lock()
+	s, ok := sessions[req.SessionID]
+	ok = ok && s.DeletedAt == nil
+	var code, text string
+	joined := false
+	switch {
+	case !ok:
+		code, text = "unknown_session", "会话不存在"
+	case members[req.SessionID][user.Username]:
+	case !s.Public:
+		code, text = "not_public", "该会话不允许自由加入"
+	case atSessionLimitLocked(user.Username):
+		code, text = "session_limit", "已达会话数量上限"
+	default:
+		addMemberLocked(req.SessionID, user.Username)
+		joined = true
+	}
+	sessMu.Unlock()
+
+	if code != "" {
+		sendError(user, code, text)
+		return
+	}
+	user.enqueue(Event{Type: "joined", Data: req.SessionID})
+	if joined {
+		backfill(user, req.SessionID)
+	}
+}
+
+// 新成员加入后推送的最近消息条数，0 表示不推送
+var joinBackfill = envInt("JOIN_BACKFILL", 20)
+
+// 返回会话中对查看者可见的最近 n 条消息（按 ID 升序）
+func recentMessages(sessionID, viewer string, n int) []Message {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	var res []Message
+	for i := len(messages) - 1; i >= 0 && len(res) < n; i-- {
+		msg := messages[i]
+		if msg.To == sessionID && !msg.Deleted && visibleTo(msg, viewer) {
+			res = append(res, msg)
+		}
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+// 向新成员推送最近消息作为上下文，结束后发送 backfill_done 事件
+func backfill(user *User, sessionID string) {
+	if joinBackfill <= 0 {
+		return
+	}
+	for _, msg := range recentMessages(sessionID, user.Username, joinBackfill) {
+		user.enqueue(msg)
+	}
+	user.enqueue(Event{Type: "backfill_done", Data: sessionID})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func softDelete(id string) {
+	now := time.Now()
+	sessMu.Lock()
+	sessions[id].DeletedAt = &now
+	sessMu.Unlock()
+}
+
+func createGroup(t *testing.T, user, name string, public bool) (*httptest.ResponseRecorder, Session) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"name": name, "public": public})
+	w := httptest.NewRecorder()
+	createSessionHandler(w, httptest.NewRequest(http.MethodPost, "/api/sessions?user="+user, strings.NewReader(string(body))))
+	var s Session
+	_ = json.Unmarshal(w.Body.Bytes(), &s)
+	return w, s
+}
+
+func TestSoftDeletedSessionHasNoUnread(t *testing.T) {
+	resetState(t)
+	newTestSession("gone", true, "alice", "bob")
+	seedMessage(Message{From: "alice", To: "gone", Content: "hi"})
+	softDelete("gone")
+
+	if counts := unreadCounts("bob"); len(counts) != 0 {
+		t.Fatalf("unread counts %v", counts)
+	}
+	if n := badgeCount("bob"); n != 0 {
+		t.Fatalf("badge %d", n)
+	}
+}
+
+func TestDedupeSkipsDeletedAndJoinsCreator(t *testing.T) {
+	resetState(t)
+	dedupe := dedupeSessionNames
+	dedupeSessionNames = true
+	t.Cleanup(func() { dedupeSessionNames = dedupe })
+
+	_, first := createGroup(t, "alice", "Lobby", true)
+	w, again := createGroup(t, "bob", "lobby", true)
+	if w.Code != http.StatusOK || again.ID != first.ID {
+		t.Fatalf("dedupe returned %d %+v", w.Code, again)
+	}
+	if !sessionMembers(first.ID)["bob"] {
+		t.Fatal("creator hitting dedupe was not added as a member")
+	}
+
+	softDelete(first.ID)
+	w, fresh := createGroup(t, "carol", "Lobby", true)
+	if w.Code != http.StatusCreated || fresh.ID == first.ID {
+		t.Fatalf("deleted session reused: %d %+v", w.Code, fresh)
+	}
+
+	_, private := createGroup(t, "alice", "Secret", false)
+	if w, _ := createGroup(t, "mallory", "secret", false); w.Code != http.StatusConflict {
+		t.Fatalf("private dedupe returned %d", w.Code)
+	}
+	if sessionMembers(private.ID)["mallory"] {
+		t.Fatal("joined a private session by name")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 用户名格式：允许的字符（正则）与长度范围（按字符计）
+var (
+	usernamePattern = regexp.MustCompile(envString("USERNAME_PATTERN", `^[\p{L}\p{N}_.-]+$`))
+	usernameMin     = envInt("USERNAME_MIN", 1)
+	usernameMax     = envInt("USERNAME_MAX", 32)
+)
+
+// 握手参数：兼容纯文本用户名与 JSON 对象两种形式
+type Handshake struct {
+	Username   string `json:"username"`
+	UnreadOnly bool   `json:"unread_only"` // 连接后推送所有未读消息
+	Acks       bool   `json:"acks"`        // 客户端会确认收到的消息，超时未确认时重发
+	Encoding   string `json:"encoding"`    // 线路编码：json（默认）或 msgpack
+	Lang       string `json:"lang"`        // 系统消息语言，如 zh、en
+	Auth       string `json:"auth"`        // 挑战应答：HMAC-SHA256(密钥, nonce:用户名) 的十六进制，启用 AUTH_SECRET 时必填
+
+	// 上次连接的恢复令牌，有效时可省略用户名并补发断线期间的消息
+	ResumeToken string `json:"resume_token"`
+}
+
+// 解析握手帧
+func parseHandshake(data []byte) (Handshake, bool) {
+	var hs Handshake
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "{") {
+		if err := json.Unmarshal(data, &hs); err != nil {
+			return hs, false
+		}
+	} else {
+		hs.Username = string(data)
+	}
+	return hs, hs.Username != "" || hs.ResumeToken != ""
+}
+
+// 校验用户名格式，失败时返回关闭原因
+func validateUsername(name string) string {
+	n := utf8.RuneCountInString(name)
+	switch {
+	case !utf8.ValidString(name):
+		return "invalid username encoding"
+	case n < usernameMin:
+		return "username too short"
+	case usernameMax > 0 && n > usernameMax:
+		return "username too long"
+	case !usernamePattern.MatchString(name):
+		return "username contains invalid characters"
+	}
+	return ""
+}
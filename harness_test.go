@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 清空全局状态，每个测试从空服务端开始；广播在调用方协程内同步完成
+func resetState(t testing.TB) {
+	t.Helper()
+	userMu.Lock()
+	users = make(map[string]map[*User]bool)
+	userMu.Unlock()
+	sessMu.Lock()
+	sessions = make(map[string]*Session)
+	sessionOrder = nil
+	members = make(map[string]map[string]bool)
+	sessMu.Unlock()
+	msgMu.Lock()
+	messages = nil
+	msgID = 1
+	streamRaw = make(map[int64]string)
+	msgMu.Unlock()
+	readMu.Lock()
+	readMarks = make(map[userSessionKey]int64)
+	readMu.Unlock()
+	recentMu.Lock()
+	recentSends = make(map[userSessionKey]recentSend)
+	recentMu.Unlock()
+	slowMu.Lock()
+	lastSendAt = make(map[userSessionKey]time.Time)
+	slowMu.Unlock()
+	floodMu.Lock()
+	floodStates = make(map[string]*floodState)
+	floodMu.Unlock()
+	resumeMu.Lock()
+	resumeTokens = make(map[string]*resumeState)
+	resumeMu.Unlock()
+	prefMu.Lock()
+	prefs = make(map[string]NotifyPrefs)
+	prefsFile = ""
+	prefMu.Unlock()
+
+	workers, st := broadcastWorkers, store
+	broadcastWorkers, store = 0, nil
+	t.Cleanup(func() { broadcastWorkers, store = workers, st })
+}
+
+// 通过内存传输层连接的测试客户端
+type testClient struct {
+	t      *testing.T
+	name   string
+	tr     *pipeTransport
+	frames chan []byte
+}
+
+// 建立连接并完成握手，等待 hello 事件
+func connect(t *testing.T, name string) *testClient {
+	t.Helper()
+	server, client := newPipeTransport()
+	go serveConn(server, "127.0.0.1")
+	c := &testClient{t: t, name: name, tr: client, frames: make(chan []byte, 256)}
+	go func() {
+		for {
+			data, err := client.Receive()
+			if err != nil {
+				close(c.frames)
+				return
+			}
+			c.frames <- data
+		}
+	}()
+	t.Cleanup(func() { _ = client.Close(CloseNormal, "") })
+	c.send(map[string]string{"username": name})
+	c.event("hello")
+	return c
+}
+
+func (c *testClient) send(v interface{}) {
+	c.t.Helper()
+	if err := c.tr.Send(v); err != nil {
+		c.t.Fatalf("%s send: %v", c.name, err)
+	}
+}
+
+// 读取下一帧，超时视为失败
+func (c *testClient) next() map[string]json.RawMessage {
+	c.t.Helper()
+	select {
+	case data, ok := <-c.frames:
+		if !ok {
+			c.t.Fatalf("%s: connection closed", c.name)
+		}
+		var v map[string]json.RawMessage
+		if err := json.Unmarshal(data, &v); err != nil {
+			c.t.Fatalf("%s: bad frame %q", c.name, data)
+		}
+		return v
+	case <-time.After(2 * time.Second):
+		c.t.Fatalf("%s: timed out", c.name)
+	}
+	return nil
+}
+
+// 跳过其他帧，直到收到指定类型的事件，返回其 data
+func (c *testClient) event(typ string) json.RawMessage {
+	c.t.Helper()
+	for {
+		v := c.next()
+		var got string
+		_ = json.Unmarshal(v["type"], &got)
+		if got == typ {
+			return v["data"]
+		}
+	}
+}
+
+// 跳过事件帧，直到收到一条聊天消息
+func (c *testClient) message() Message {
+	c.t.Helper()
+	for {
+		v := c.next()
+		if _, isEvent := v["type"]; isEvent {
+			continue
+		}
+		raw, _ := json.Marshal(v)
+		var msg Message
+		_ = json.Unmarshal(raw, &msg)
+		return msg
+	}
+}
+
+// 确认在短时间内没有收到指定类型的事件
+func (c *testClient) noEvent(typ string, wait time.Duration) {
+	c.t.Helper()
+	deadline := time.After(wait)
+	for {
+		select {
+		case data, ok := <-c.frames:
+			if !ok {
+				return
+			}
+			var v struct {
+				Type string `json:"type"`
+			}
+			if json.Unmarshal(data, &v) == nil && v.Type == typ {
+				c.t.Fatalf("%s: unexpected %s event: %s", c.name, typ, data)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// 创建会话并加入成员
+func newTestSession(id string, isGroup bool, names ...string) {
+	addSession(Session{ID: id, Name: id, IsGroup: isGroup})
+	for _, n := range names {
+		addMember(id, n)
+	}
+}
+
+// 示例：两个客户端在同一会话中对话
+func TestConversationThroughPipeTransport(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	alice := connect(t, "alice")
+	bob := connect(t, "bob")
+
+	alice.send(map[string]string{"to": "room", "content": "hi bob"})
+	if got := bob.message(); got.From != "alice" || got.Content != "hi bob" {
+		t.Fatalf("bob got %+v", got)
+	}
+	if got := alice.message(); got.Content != "hi bob" || got.ID == 0 {
+		t.Fatalf("alice echo %+v", got)
+	}
+
+	bob.send(map[string]string{"to": "room", "content": "hello alice"})
+	if got := alice.message(); got.From != "bob" || got.Content != "hello alice" {
+		t.Fatalf("alice got %+v", got)
+	}
+}
+
+// 示例：发往不存在的会话时收到错误事件
+func TestUnknownSessionThroughPipeTransport(t *testing.T) {
+	resetState(t)
+	alice := connect(t, "alice")
+	alice.send(map[string]string{"to": "nowhere", "content": "hi"})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "unknown_session" {
+		t.Fatalf("got error %+v", info)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 同时执行的重量级读请求（历史、导出）上限，0 表示不限制；超出时最多排队等待 heavyQueueWait
+var (
+	heavyRequests  = envInt("HEAVY_REQUESTS", 8)
+	heavyQueueWait = envDuration("HEAVY_QUEUE_WAIT", 2*time.Second)
+)
+
+var heavySem = make(chan struct{}, max(heavyRequests, 0))
+
+// 为重量级接口加并发限制，排队超时返回 429
+func limitHeavy(h http.HandlerFunc) http.HandlerFunc {
+	if heavyRequests <= 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		timer := time.NewTimer(heavyQueueWait)
+		defer timer.Stop()
+		select {
+		case heavySem <- struct{}{}:
+		case <-timer.C:
+			w.Header().Set("Retry-After", strconv.Itoa(int(heavyQueueWait/time.Second)+1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		case <-r.Context().Done():
+			return
+		}
+		defer func() { <-heavySem }()
+		h(w, r)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 设置重量级请求并发上限与排队时间，测试结束后恢复
+func withHeavyLimit(t *testing.T, n int, wait time.Duration) {
+	t.Helper()
+	limit, queue, sem := heavyRequests, heavyQueueWait, heavySem
+	heavyRequests, heavyQueueWait, heavySem = n, wait, make(chan struct{}, n)
+	t.Cleanup(func() { heavyRequests, heavyQueueWait, heavySem = limit, queue, sem })
+}
+
+func TestHeavyRequestsThrottled(t *testing.T) {
+	resetState(t)
+	withHeavyLimit(t, 2, 20*time.Millisecond)
+	newTestSession("room", true, "alice")
+	seedMessage(Message{From: "alice", To: "room", Content: "hi"})
+
+	// 前 N 个请求占住名额直到 release 关闭
+	started := make(chan struct{})
+	release := make(chan struct{})
+	blocking := limitHeavy(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			blocking(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/export", nil))
+		}()
+		<-started
+	}
+
+	export := limitHeavy(exportHandler)
+	req := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		export(w, httptest.NewRequest(http.MethodGet, "/api/export?session_id=room&user=alice", nil))
+		return w
+	}
+	w := req()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("N+1th request got %d (Retry-After %q)", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	wg.Wait()
+	if w := req(); w.Code != http.StatusOK {
+		t.Fatalf("request after release got %d", w.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+// 服务端版本
+const serverVersion = "1.1.0"
+
+// 服务端能力声明，客户端据此做功能探测
+type Capabilities struct {
+	Reactions   bool `json:"reactions"`
+	Editing     bool `json:"editing"`
+	Attachments bool `json:"attachments"`
+	Compression bool `json:"compression"`
+	Archive     bool `json:"archive"`
+	Membership  bool `json:"membership"`
+	GapNotice   bool `json:"gap_notice"`
+	MessagePack bool `json:"msgpack"`
+}
+
+// 连接建立后下发的 hello 事件内容
+type Hello struct {
+	Version      string       `json:"version"`
+	Capabilities Capabilities `json:"capabilities"`
+	ResumeToken  string       `json:"resume_token,omitempty"` // 断线重连时在握手中回传
+}
+
+// 根据当前启用的功能生成能力声明
+func serverCapabilities() Capabilities {
+	return Capabilities{
+		Archive:     true,
+		Membership:  true,
+		GapNotice:   true,
+		MessagePack: true,
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 系统消息的默认语言，历史记录中保存该语言的文本
+const defaultLang = "zh"
+
+// 系统消息文案，参数按 %s 顺序填入
+var catalog = map[string]map[string]string{
+	"zh": {
+		"pinned":   "%s 置顶了一条消息",
+		"unpinned": "%s 取消置顶了一条消息",
+	},
+	"en": {
+		"pinned":   "%s pinned a message",
+		"unpinned": "%s unpinned a message",
+	},
+}
+
+// 规范化语言标识（如 en-US → en），不支持的语言回退到默认语言
+func normalizeLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if _, ok := catalog[lang]; !ok {
+		return defaultLang
+	}
+	return lang
+}
+
+// 按语言取文案并填入参数，缺少译文时使用默认语言
+func translate(lang, key string, args ...string) string {
+	format, ok := catalog[normalizeLang(lang)][key]
+	if !ok {
+		format = catalog[defaultLang][key]
+	}
+	vals := make([]interface{}, len(args))
+	for i, a := range args {
+		vals[i] = a
+	}
+	return fmt.Sprintf(format, vals...)
+}
+
+// 将带文案键的系统消息转为接收者的语言
+func localize(msg Message, lang string) Message {
+	if !msg.IsSystem || msg.Key == "" {
+		return msg
+	}
+	msg.Content = translate(lang, msg.Key, msg.Args...)
+	return msg
+}
+
+// 取欢迎语：优先使用 WELCOME_MESSAGE_<语言>（如 WELCOME_MESSAGE_EN），否则使用 WELCOME_MESSAGE
+func welcomeText(lang string) string {
+	if v := os.Getenv("WELCOME_MESSAGE_" + strings.ToUpper(normalizeLang(lang))); v != "" {
+		return v
+	}
+	return welcomeMessage
+}
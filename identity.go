@@ -0,0 +1,16 @@
+package main
+
+// 身份解析接口：根据用户名返回展示名称与头像地址，便于对接外部用户目录
+type IdentityResolver interface {
+	Resolve(username string) (displayName, avatar string)
+}
+
+// 默认解析器：展示名即用户名，头像取用户名首字符
+type defaultIdentityResolver struct{}
+
+func (defaultIdentityResolver) Resolve(username string) (string, string) {
+	return username, string(username[0])
+}
+
+// 当前使用的身份解析器，部署方可替换为自己的实现
+var identityResolver IdentityResolver = defaultIdentityResolver{}
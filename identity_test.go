@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+// 测试用解析器：只覆盖已登记的用户
+type fakeResolver map[string][2]string
+
+func (f fakeResolver) Resolve(username string) (string, string) {
+	if v, ok := f[username]; ok {
+		return v[0], v[1]
+	}
+	return defaultIdentityResolver{}.Resolve(username)
+}
+
+func withResolver(t *testing.T, r IdentityResolver) {
+	t.Helper()
+	old, enabled := identityResolver, avatarsEnabled
+	identityResolver, avatarsEnabled = r, true
+	t.Cleanup(func() { identityResolver, avatarsEnabled = old, enabled })
+}
+
+func TestIdentityResolverOverridesAvatar(t *testing.T) {
+	resetState(t)
+	withResolver(t, fakeResolver{"alice": {"Alice Liddell", "https://cdn.example.com/alice.png"}})
+	connect(t, "alice")
+	connect(t, "bob")
+
+	u := onlineUser("alice")
+	if u == nil || u.DisplayName != "Alice Liddell" || u.Avatar != "https://cdn.example.com/alice.png" {
+		t.Fatalf("alice connected as %+v", u)
+	}
+	if p, ok := userProfile("alice"); !ok || p.DisplayName != "Alice Liddell" || p.Avatar != "https://cdn.example.com/alice.png" {
+		t.Fatalf("profile %+v", p)
+	}
+	// 未被覆盖的用户保持默认行为
+	if b := onlineUser("bob"); b == nil || b.DisplayName != "bob" || b.Avatar != "b" {
+		t.Fatalf("bob connected as %+v", b)
+	}
+}
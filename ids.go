@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// 分配新的消息 ID，是消息 ID 的唯一来源（调用方需持有 msgMu）
+func allocMessageIDLocked() int64 {
+	id := msgID
+	msgID++
+	return id
+}
+
+// 导入时被重新分配 ID 的消息
+type Reassigned struct {
+	OldID int64 `json:"old_id"`
+	NewID int64 `json:"new_id"`
+}
+
+// 导入结果
+type ImportResult struct {
+	Imported   int          `json:"imported"`
+	Skipped    int          `json:"skipped"` // 目标会话不存在
+	Reassigned []Reassigned `json:"reassigned"`
+}
+
+// 导入消息：与现有消息冲突或非法的 ID 重新分配，保证 ID 唯一且消息按 ID 有序
+func importMessages(list []Message) ImportResult {
+	res := ImportResult{Reassigned: make([]Reassigned, 0)}
+	var accepted []Message
+	for _, msg := range list {
+		if _, ok := getSession(msg.To); !ok {
+			res.Skipped++
+			continue
+		}
+		accepted = append(accepted, msg)
+	}
+
+	msgMu.Lock()
+	used := make(map[int64]bool, len(messages)+len(accepted))
+	for _, msg := range messages {
+		used[msg.ID] = true
+	}
+	// 先保留合法且不冲突的 ID，并推进分配器避免日后冲突
+	for _, msg := range accepted {
+		if msg.ID > 0 && !used[msg.ID] && msg.ID >= msgID {
+			msgID = msg.ID + 1
+		}
+	}
+	now := time.Now()
+	remap := make(map[int64]int64)
+	for i, msg := range accepted {
+		if msg.ID <= 0 || used[msg.ID] {
+			newID := allocMessageIDLocked()
+			res.Reassigned = append(res.Reassigned, Reassigned{OldID: msg.ID, NewID: newID})
+			accepted[i].ID = newID
+			if _, seen := remap[msg.ID]; !seen && msg.ID > 0 {
+				remap[msg.ID] = newID
+			}
+		}
+		used[accepted[i].ID] = true
+		if accepted[i].Timestamp.IsZero() {
+			accepted[i].Timestamp = now
+		}
+		accepted[i].UpdatedAt = now // 增量同步需要返回新导入的消息
+	}
+	// 批内的回复、引用与话题指向被重新分配的 ID 时随之更新
+	for i := range accepted {
+		remapRefs(&accepted[i], remap)
+	}
+	messages = append(messages, accepted...)
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	migrateReadByLocked()
+	trackMessagesLocked(accepted...)
+	persistLocked(accepted...)
+	msgMu.Unlock()
+	flushStore()
+
+	for _, msg := range accepted {
+		if s, ok := getSession(msg.To); ok && msg.Timestamp.After(s.LastTime) {
+			touchSession(msg.To, msg.Content, msg.Timestamp)
+		}
+	}
+	res.Imported = len(accepted)
+	return res
+}
+
+// 按旧 ID 到新 ID 的映射改写消息中引用的其他消息 ID
+func remapRefs(m *Message, remap map[int64]int64) {
+	if len(remap) == 0 {
+		return
+	}
+	fix := func(id *int64) {
+		if n, ok := remap[*id]; ok {
+			*id = n
+		}
+	}
+	fix(&m.ReplyTo)
+	fix(&m.ThreadID)
+	for i := range m.ReplyToIDs {
+		fix(&m.ReplyToIDs[i])
+	}
+	if m.Quoted != nil {
+		q := *m.Quoted
+		fix(&q.ID)
+		m.Quoted = &q
+	}
+	for i, q := range m.Quotes {
+		if q != nil {
+			c := *q
+			fix(&c.ID)
+			m.Quotes[i] = &c
+		}
+	}
+}
+
+// 批量导入消息（管理员）
+func importHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var list []Message
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res := importMessages(list)
+	audit(adminActor(r), "import_messages", "", fmt.Sprintf("imported=%d skipped=%d reassigned=%d", res.Imported, res.Skipped, len(res.Reassigned)))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
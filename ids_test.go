@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImportRemapsReferencesWithinBatch(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	existing := seedMessage(Message{From: "alice", To: "room", Content: "already here"}) // 占用 ID 1
+	since := time.Now()
+
+	res := importMessages([]Message{
+		{ID: 1, From: "bob", To: "room", Content: "root"},
+		{ID: 2, From: "bob", To: "room", Content: "reply", ReplyTo: 1, ThreadID: 1, ReplyToIDs: []int64{1}, Quoted: &QuotedSnippet{ID: 1}},
+	})
+	if res.Imported != 2 || len(res.Reassigned) != 1 || res.Reassigned[0].OldID != 1 {
+		t.Fatalf("result %+v", res)
+	}
+	root := res.Reassigned[0].NewID
+	reply, _ := findMessage(2)
+	if reply.ReplyTo != root || reply.ThreadID != root || reply.ReplyToIDs[0] != root || reply.Quoted.ID != root {
+		t.Fatalf("reply refs %+v, want %d", reply, root)
+	}
+	if reply.ThreadID == existing.ID {
+		t.Fatal("reply points at the pre-existing message")
+	}
+	if reply.UpdatedAt.Before(since) {
+		t.Fatalf("updated_at %v not set", reply.UpdatedAt)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 踢出用户（管理员）：POST /api/admin/kick?user=[&reason=]，断开其全部连接，客户端收到后不应自动重连
+func kickHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	username := r.URL.Query().Get("user")
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	conns := userConns(username)
+	if len(conns) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "kicked"
+	}
+	for _, u := range conns {
+		_ = u.WS.Close(CloseKicked, reason)
+	}
+	audit(adminActor(r), "kick", username, r.URL.Query().Get("reason"))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"kicked": len(conns)})
+}
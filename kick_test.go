@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKickClosesConnectionsAndAudits(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	bob := connect(t, "bob")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/kick?user=bob&reason=spam", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	req.Header.Set("X-Admin-User", "root")
+	w := httptest.NewRecorder()
+	kickHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("kick returned %d", w.Code)
+	}
+	for range bob.frames {
+	}
+	if code := bob.tr.peer.CloseCode; code != CloseKicked {
+		t.Fatalf("close code %d, want %d", code, CloseKicked)
+	}
+
+	auditMu.Lock()
+	last := auditLog[len(auditLog)-1]
+	auditMu.Unlock()
+	if last.Action != "kick" || last.Actor != "root" || last.Target != "bob" || last.Detail != "spam" {
+		t.Fatalf("audit entry %+v", last)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(userConns("bob")) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	w = httptest.NewRecorder()
+	kickHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("kicking an offline user returned %d", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 投递延迟直方图的桶上界（毫秒），超出最后一个桶的样本计入溢出桶
+var latencyBounds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+// 轻量直方图：固定桶计数，百分位取所在桶的上界
+type histogram struct {
+	mu     sync.Mutex
+	counts []int64 // 长度为 len(latencyBounds)+1
+	total  int64
+}
+
+var deliveryLatency = &histogram{counts: make([]int64, len(latencyBounds)+1)}
+
+// 记录一个样本
+func (h *histogram) observe(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	i := 0
+	for i < len(latencyBounds) && ms > latencyBounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.counts[i]++
+	h.total++
+	h.mu.Unlock()
+}
+
+// 返回 q 分位（0~1）所在桶的上界，落入溢出桶时返回 -1，无样本时返回 0
+func (h *histogram) quantile(q float64) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			if i == len(latencyBounds) {
+				return -1
+			}
+			return latencyBounds[i]
+		}
+	}
+	return -1
+}
+
+func (h *histogram) count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// 实时投递的消息，携带服务端收到消息的时间用于统计延迟
+type timedMessage struct {
+	msg        Message
+	receivedAt time.Time
+}
+
+// 服务端运行指标
+type Metrics struct {
+	DeliveryCount int64   `json:"delivery_count"`
+	LatencyP50Ms  float64 `json:"delivery_latency_p50_ms"`
+	LatencyP95Ms  float64 `json:"delivery_latency_p95_ms"`
+	DroppedTotal  int64   `json:"dropped_total"`
+}
+
+// 查看运行指标（管理员）：GET /api/admin/metrics
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	m := Metrics{
+		DeliveryCount: deliveryLatency.count(),
+		LatencyP50Ms:  deliveryLatency.quantile(0.50),
+		LatencyP95Ms:  deliveryLatency.quantile(0.95),
+		DroppedTotal:  atomic.LoadInt64(&droppedTotal),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	h := &histogram{counts: make([]int64, len(latencyBounds)+1)}
+	if h.quantile(0.5) != 0 || h.count() != 0 {
+		t.Fatal("empty histogram should report zero")
+	}
+	for i := 0; i < 90; i++ {
+		h.observe(3 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.observe(150 * time.Millisecond)
+	}
+	if h.count() != 100 {
+		t.Fatalf("count %d", h.count())
+	}
+	if got := h.quantile(0.50); got != 5 {
+		t.Fatalf("p50 %v, want bucket 5", got)
+	}
+	if got := h.quantile(0.95); got != 200 {
+		t.Fatalf("p95 %v, want bucket 200", got)
+	}
+	h.observe(time.Minute)
+	if got := h.quantile(1); got != -1 {
+		t.Fatalf("overflow sample reported %v", got)
+	}
+}
+
+// 实时投递的消息写出后计入延迟直方图
+func TestDeliveryRecordsLatency(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	alice := connect(t, "alice")
+	bob := connect(t, "bob")
+
+	// 直方图为全局变量，只比较本次投递前后的样本数
+	before := deliveryLatency.count()
+	alice.send(map[string]string{"to": "room", "content": "hi"})
+	bob.message()
+	deadline := time.Now().Add(2 * time.Second)
+	for deliveryLatency.count() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("delivery to bob was not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"golang.org/x/net/websocket"
@@ -13,150 +20,821 @@ import (
 
 // 用户结构
 type User struct {
-	Username string `json:"username"`
-	Avatar   string `json:"avatar"`
-	WS       *websocket.Conn
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Avatar      string    `json:"avatar,omitempty"`
+	Presence    string    `json:"presence"`
+	LastActive  time.Time `json:"last_active"`
+	WS          Transport `json:"-"`
+
+	ip            string      // 连接来源 IP
+	authenticated bool        // 握手时通过了挑战认证
+	lang          string      // 系统消息语言
+	mu            sync.Mutex  // 保护 Presence、LastActive 与 autoAway
+	autoAway      bool        // 因空闲自动切换为离开
+	replaced      atomic.Bool // 被同一用户的新连接顶替
+
+	acks    bool // 客户端会对消息回复 ack
+	ackMu   sync.Mutex
+	pending map[int64]*pendingAck // 等待确认的消息
+
+	send   chan interface{} // 发送队列，由 writeLoop 串行写出
+	urgent chan interface{} // 高优先级发送队列，先于 send 写出
+	done   chan struct{}
+	gapMu  sync.Mutex
+	gap    *Gap // 尚未通知客户端的丢弃区间
+}
+
+// 创建在线用户并初始化发送队列
+func newUser(username, ip string, t Transport) *User {
+	displayName, avatar := identityResolver.Resolve(username)
+	if !avatarsEnabled {
+		avatar = ""
+	}
+	return &User{
+		Username:    username,
+		DisplayName: displayName,
+		Avatar:      avatar,
+		Presence:    PresenceOnline,
+		LastActive:  time.Now(),
+		WS:          t,
+		ip:          ip,
+		pending:     make(map[int64]*pendingAck),
+		send:        make(chan interface{}, sendQueueSize),
+		urgent:      make(chan interface{}, sendQueueSize),
+		done:        make(chan struct{}),
+	}
 }
 
 // 消息结构（对齐 Telegram 消息字段）
 type Message struct {
-	ID        int64     `json:"id"`
-	From      string    `json:"from"`
-	To        string    `json:"to"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
-	IsRead    bool      `json:"is_read"`
-	Avatar    string    `json:"avatar"`
+	ID          int64             `json:"id"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Content     string            `json:"content"`
+	Timestamp   time.Time         `json:"timestamp"`
+	IsRead      bool              `json:"is_read"`
+	Avatar      string            `json:"avatar,omitempty"`
+	ReadBy      []string          `json:"read_by,omitempty"`      // 已读用户列表
+	DeliveredTo []string          `json:"delivered_to,omitempty"` // 已送达用户列表，接收者任一设备确认后加入
+	ReplyTo     int64             `json:"reply_to,omitempty"`     // 回复的消息 ID
+	Quoted      *QuotedSnippet    `json:"quoted,omitempty"`       // 被回复消息的片段
+	ReplyToIDs  []int64           `json:"reply_to_ids,omitempty"` // 同时回复的多条消息 ID，首条与 ReplyTo 一致
+	Quotes      []*QuotedSnippet  `json:"quotes,omitempty"`       // 多条被回复消息的片段，与 ReplyToIDs 一一对应
+	Streaming   bool              `json:"streaming,omitempty"`    // 流式消息，完成前可追加内容
+	UpdatedAt   time.Time         `json:"updated_at"`             // 最后修改时间，用于增量同步
+	Deleted     bool              `json:"deleted,omitempty"`      // 删除墓碑
+	Whisper     []string          `json:"whisper_to,omitempty"`   // 悄悄话接收者，非空时仅发送者与接收者可见
+	IsSystem    bool              `json:"is_system,omitempty"`    // 服务端生成的系统消息
+	Priority    int               `json:"priority,omitempty"`     // 投递优先级，由服务端设置
+	Forwarded   *ForwardInfo      `json:"forwarded,omitempty"`    // 转发来源，仅由 forward 帧设置
+	Key         string            `json:"key,omitempty"`          // 系统消息文案键，用于按接收者语言本地化
+	Args        []string          `json:"args,omitempty"`         // 文案参数
+	SeenCount   int               `json:"seen_count,omitempty"`   // 已读人数，仅在历史查询 seen=1 时填充
+	ThreadID    int64             `json:"thread_id,omitempty"`    // 所属话题的根消息 ID
+	Preview     *LinkPreview      `json:"preview,omitempty"`      // 链接预览，由服务端异步生成
+	Meta        map[string]string `json:"meta,omitempty"`         // 客户端附加的元数据，服务端原样保存与返回
 }
 
 // 会话结构
 type Session struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Avatar   string `json:"avatar"`
-	IsGroup  bool   `json:"is_group"`
-	LastMsg  string `json:"last_msg"`
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Avatar   string    `json:"avatar,omitempty"`
+	IsGroup  bool      `json:"is_group"`
+	LastMsg  string    `json:"last_msg"`
 	LastTime time.Time `json:"last_time"`
-	Unread   int    `json:"unread"`
+	Unread   int       `json:"unread"`
+	Archived bool      `json:"archived"`  // 归档后只读，不再接收新消息
+	Public   bool      `json:"public"`    // 公开会话，首次访问自动加入
+	Pinned   []int64   `json:"pinned"`    // 置顶消息 ID，按置顶先后排列
+	SlowMode int       `json:"slow_mode"` // 慢速模式：同一用户两次发送的最小间隔（秒），0 表示关闭
+
+	Owner         string     `json:"owner,omitempty"`          // 创建者，拥有会话管理员角色
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`     // 软删除时间，恢复期内可由管理员恢复
+	RetentionDays *int       `json:"retention_days,omitempty"` // 消息保留天数，未设置时继承全局，0 表示永久保留
+	AutoArchived  bool       `json:"auto_archived,omitempty"`  // 因长期不活跃被自动归档，新消息会恢复
+}
+
+// 单个会话详情
+type SessionDetail struct {
+	Session
+	MessageCount int `json:"message_count"`
+	MemberCount  int `json:"member_count"`
+}
+
+// 服务端事件（区别于普通消息推送）
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// 错误事件内容
+type ErrorInfo struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after,omitempty"` // 需等待的秒数
 }
 
 var (
-	users     = make(map[string]*User)
-	messages  []Message
-	sessions  []Session
-	userMu    sync.Mutex
-	msgMu     sync.Mutex
-	msgID     int64 = 1
+	users        = make(map[string]map[*User]bool) // 按用户名索引的在线连接，multi 策略下同一用户可有多个
+	messages     []Message
+	sessions     = make(map[string]*Session) // 按 ID 索引的会话
+	sessionOrder []string                    // 会话创建顺序，用于列表输出
+	userMu       sync.Mutex
+	msgMu        sync.Mutex
+	sessMu       sync.Mutex
+	msgID        int64 = 1
 )
 
 // 初始化默认公共聊天室
 func init() {
-	sessions = append(sessions, Session{
+	addSession(Session{
 		ID:       "public-chat",
 		Name:     "公共聊天室",
 		Avatar:   "https://img.icons8.com/fluency/96/000000/chat.png",
 		IsGroup:  true,
+		Public:   true,
 		LastMsg:  "欢迎加入公共聊天室",
 		LastTime: time.Now(),
 	})
 }
 
-// 广播消息给所有在线用户
-func broadcast(msg Message) {
+// 添加会话
+func addSession(s Session) {
+	if !avatarsEnabled {
+		s.Avatar = ""
+	}
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	if _, ok := sessions[s.ID]; !ok {
+		sessionOrder = append(sessionOrder, s.ID)
+	}
+	sessions[s.ID] = &s
+}
+
+// 更新会话最后一条消息，会话不存在时返回 false
+func touchSession(id, lastMsg string, t time.Time) bool {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	s, ok := sessions[id]
+	if !ok {
+		return false
+	}
+	s.LastMsg = lastMsg
+	s.LastTime = t
+	return true
+}
+
+// 获取单个会话快照
+func getSession(id string) (Session, bool) {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	s, ok := sessions[id]
+	if !ok || s.DeletedAt != nil {
+		return Session{}, false
+	}
+	return *s, true
+}
+
+// 按创建顺序返回会话列表快照
+func listSessions() []Session {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	res := make([]Session, 0, len(sessionOrder)) // 非 nil，空列表编码为 []
+	for _, id := range sessionOrder {
+		if s := sessions[id]; s.DeletedAt == nil {
+			res = append(res, *s)
+		}
+	}
+	return res
+}
+
+// 向单个连接发送错误事件
+func sendError(u *User, code, message string) {
+	u.enqueue(Event{Type: "error", Data: ErrorInfo{Code: code, Message: message}})
+}
+
+// 投递消息给会话内的在线成员
+func deliver(msg Message) {
+	recipients := sessionMembers(msg.To)
+	intended := 0
+	for name := range recipients {
+		if name != msg.From && visibleTo(msg, name) {
+			intended++
+		}
+	}
+
+	online, delivered, offline := deliverOnline(msg, recipients)
+	if len(offline) > 0 {
+		go pushOffline(msg, offline)
+	}
+
+	// 没有任何接收者收到时记入死信
+	if intended > 0 && delivered == 0 {
+		reason := DeadAllOffline
+		if online > 0 {
+			reason = DeadQueueFull
+		}
+		recordDeadLetter(msg, reason, intended)
+	}
+}
+
+// 投递给在线接收者，返回在线接收者数、成功入队的接收者数与离线接收者
+func deliverOnline(msg Message, recipients map[string]bool) (online, delivered int, offline []string) {
+	userMu.Lock()
+	defer userMu.Unlock()
+	for name := range recipients {
+		if name != msg.From && visibleTo(msg, name) && len(users[name]) == 0 {
+			offline = append(offline, name)
+		}
+	}
+	for name, conns := range users {
+		if name == msg.From || !recipients[name] || !visibleTo(msg, name) {
+			continue
+		}
+		online++
+		ok := false
+		for u := range conns {
+			m := localize(msg, u.lang)
+			if u.deliverMessage(m) {
+				ok = true
+			}
+			if u.presence() != PresenceDND && shouldNotify(u.Username, m) {
+				u.enqueue(notification(m))
+			}
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return online, delivered, offline
+}
+
+// 向会话内所有在线成员推送事件：与消息经同一会话分片投递，事件不会先于其引用的消息到达；
+// 接收者在调用时确定
+func broadcastEvent(sessionID string, ev Event) {
+	recipients := sessionMembers(sessionID)
+	runInSession(sessionID, func() { deliverEvent(recipients, ev) })
+}
+
+func deliverEvent(recipients map[string]bool, ev Event) {
 	userMu.Lock()
 	defer userMu.Unlock()
-	for _, u := range users {
-		if u.Username == msg.From {
+	for name, conns := range users {
+		if !recipients[name] {
 			continue
 		}
-		_ = websocket.JSON.Send(u.WS, msg)
+		for u := range conns {
+			u.enqueue(ev)
+		}
+	}
+}
+
+// 按 ID 查找未删除的消息
+func findMessage(id int64) (Message, bool) {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	for _, msg := range messages {
+		if msg.ID == id && !msg.Deleted {
+			return msg, true
+		}
 	}
+	return Message{}, false
 }
 
 // WebSocket 处理连接
 func wsHandler(ws *websocket.Conn) {
-	defer ws.Close()
+	serveConn(&wsTransport{ws: ws}, remoteIP(ws.Request()))
+}
 
-	// 握手获取用户名
+// 处理单个客户端连接
+func serveConn(t Transport, ip string) {
+	defer t.Close(CloseNormal, "")
+
+	// 单个连接内的 panic 只断开该连接，不影响服务与其他客户端
 	var username string
-	if err := websocket.Message.Receive(ws, &username); err != nil || username == "" {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("连接异常已恢复: user=%q ip=%s err=%v\n%s", username, ip, err, debug.Stack())
+			_ = t.Close(CloseInternalError, "internal error")
+		}
+	}()
+
+	// 限制单个 IP 新建连接的速率，在读取握手前拒绝
+	if !allowConnection(ip, time.Now()) {
+		_ = t.Close(CloseRateLimited, "too many connections")
 		return
 	}
 
-	// 注册用户
-	userMu.Lock()
-	users[username] = &User{
-		Username: username,
-		Avatar:   string(username[0]),
-		WS:       ws,
+	// 启用共享密钥时先下发挑战码，握手需携带对应的 HMAC 应答
+	var nonce string
+	if challengeEnabled() {
+		var ok bool
+		if nonce, ok = issueNonce(time.Now()); !ok {
+			_ = t.Close(CloseInternalError, "internal error")
+			return
+		}
+		if err := t.Send(Event{Type: "challenge", Data: Challenge{Nonce: nonce}}); err != nil {
+			return
+		}
+	}
+
+	// 握手获取用户名及连接参数
+	data, err := t.Receive()
+	if err != nil {
+		_ = t.Close(CloseBadHandshake, "handshake not received")
+		return
+	}
+	// 重连风暴时限制同时处理的握手数，注册完成即释放名额
+	release, ok := admitHandshake()
+	if !ok {
+		_ = t.Close(CloseRateLimited, "server busy")
+		return
+	}
+	defer release()
+	hs, ok := parseHandshake(data)
+	if !ok {
+		_ = t.Close(CloseBadHandshake, "invalid handshake")
+		return
+	}
+	// 有效的恢复令牌直接恢复原用户，失效时退回普通握手；
+	// 先只查看令牌确定用户名，认证通过后才消耗，未认证的连接无法作废他人的令牌
+	if hs.ResumeToken != "" {
+		if st, ok := peekResumeToken(hs.ResumeToken, time.Now()); ok {
+			hs.Username = st.username
+		} else if hs.Username == "" {
+			_ = t.Close(CloseBadHandshake, "resume token expired")
+			return
+		}
+	}
+	if reason := validateUsername(hs.Username); reason != "" {
+		_ = t.Close(CloseBadHandshake, reason)
+		return
+	}
+	if challengeEnabled() && !verifyChallenge(nonce, hs.Username, hs.Auth, time.Now()) {
+		_ = t.Close(CloseAuthFailed, "authentication failed")
+		return
+	}
+	var resumed *resumeState
+	if hs.ResumeToken != "" {
+		if st, ok := consumeResumeToken(hs.ResumeToken, time.Now()); ok && st.username == hs.Username {
+			resumed = &st
+		}
+	}
+	// 系统发送者名称保留，防止冒充系统消息
+	if hs.Username == systemSender {
+		_ = t.Close(CloseBadHandshake, "reserved username")
+		return
+	}
+	// 协商线路编码，默认 JSON
+	if hs.Encoding != "" && hs.Encoding != encodingJSON {
+		if n, ok := t.(encodingNegotiator); !ok || !n.SetEncoding(hs.Encoding) {
+			_ = t.Close(CloseBadHandshake, "unsupported encoding")
+			return
+		}
+	}
+	username = hs.Username
+	if isBanned(username, ip) {
+		_ = t.Close(CloseBanned, "banned")
+		return
+	}
+
+	// 注册用户（展示名与头像由身份解析器提供）
+	user := newUser(username, ip, t)
+	user.acks = hs.Acks
+	user.authenticated = challengeEnabled() // 启用时走到这里即已通过校验
+	user.lang = normalizeLang(hs.Lang)
+	resumeToken := issueResumeToken(username)
+	user.enqueue(Event{Type: "hello", Data: Hello{Version: serverVersion, Capabilities: serverCapabilities(), ResumeToken: resumeToken}})
+	if !registerUser(user) {
+		revokeResumeToken(resumeToken)
+		_ = t.Close(CloseAlreadyConnected, "already connected")
+		return
+	}
+	release()
+	go user.writeLoop()
+	go user.idleLoop()
+	joinPublicSessions(username)
+	if resumed == nil {
+		sendWelcome(user)
+	}
+	if list := userDrafts(username); len(list) > 0 {
+		user.enqueue(Event{Type: "drafts", Data: list})
+	}
+	if resumed != nil {
+		replayMissed(user, resumed.lastID)
+	} else if hs.UnreadOnly {
+		replayUnread(user)
 	}
-	userMu.Unlock()
 
 	// 退出时注销用户
 	defer func() {
-		userMu.Lock()
-		delete(users, username)
-		userMu.Unlock()
+		recordLastSeen(user)
+		unregisterUser(user)
+		close(user.done)
+		user.stopAcks()
+		// 被顶替时用户仍在线，启用旧令牌会让其踢掉新连接
+		if user.replaced.Load() {
+			revokeResumeToken(resumeToken)
+		} else {
+			releaseResumeToken(resumeToken)
+		}
 	}()
 
-	// 循环接收消息
+	// 循环接收客户端帧
 	for {
-		var msg Message
-		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		data, err := t.Receive()
+		if errors.Is(err, errUnsupportedFrame) {
+			sendError(user, "unsupported_frame", "未协商二进制编码，仅支持文本帧")
+			continue
+		}
+		if errors.Is(err, errBadFragmentation) {
+			_ = t.Close(CloseProtocolError, "bad fragmentation")
+			break
+		}
+		if errors.Is(err, errFrameTooLarge) {
+			_ = t.Close(CloseMessageTooBig, "message too large")
 			break
 		}
+		if err != nil {
+			break
+		}
+		user.touch()
+		handleFrame(user, data)
+	}
+}
+
+// 处理聊天消息
+func handleMessage(user *User, msg Message) {
+	// 目标会话必须存在，避免产生无法访问的孤立消息
+	s, ok := getSession(msg.To)
+	if !ok {
+		sendError(user, "unknown_session", "会话不存在")
+		return
+	}
+
+	// 只接受会话成员发送的消息
+	msg.From = user.Username
+	if !ensureMember(msg.To, user.Username) {
+		sendError(user, "not_member", "你不是该会话成员")
+		return
+	}
+
+	// 归档会话只读；自动归档的会话在消息通过全部校验、即将存储时恢复
+	if s.Archived && !s.AutoArchived {
+		sendError(user, "session_archived", "会话已归档，无法发送消息")
+		return
+	}
+
+	// 反复刷屏被自动禁言期间拒绝发送
+	now := time.Now()
+	if wait := muteRemaining(user.Username, now); wait > 0 {
+		user.enqueue(Event{Type: "error", Data: ErrorInfo{Code: "muted", Message: "发送过于频繁，已被暂时禁言", RetryAfter: wait}})
+		return
+	}
+	if !allowMessage(user.Username, now) {
+		sendError(user, "rate_limited", "发送过于频繁，请稍后再试")
+		floodViolation(user, now)
+		return
+	}
+
+	// 依次应用内容转换管道，流式消息保留原文供追加时整体重新转换
+	raw := msg.Content
+	msg.Content = applyTransforms(contentPipeline, msg.Content)
+
+	// 限制行数与 @ 人数，防止刷屏
+	if code, text := checkFormatting(msg.Content); code != "" {
+		sendError(user, code, text)
+		return
+	}
+	if code, text := checkMeta(msg.Meta); code != "" {
+		sendError(user, code, text)
+		return
+	}
+
+	// 部署方自定义的校验规则
+	if err := messageValidator.Validate(msg, s); err != nil {
+		sendError(user, "rejected", err.Error())
+		return
+	}
+
+	// 慢速模式冷却中，告知剩余等待时间
+	if wait := slowModeRemaining(s, user.Username, now); wait > 0 {
+		user.enqueue(Event{Type: "error", Data: ErrorInfo{Code: "slow_mode", Message: "慢速模式，请稍后再发送", RetryAfter: wait}})
+		floodViolation(user, now)
+		return
+	}
+
+	// 短时间内重复发送的相同内容视为误触或重试，合并为一条
+	if id, dup := findDuplicate(msg, time.Now()); dup {
+		user.enqueue(Event{Type: "deduplicated", Data: DedupeEvent{SessionID: msg.To, ID: id}})
+		return
+	}
+
+	// 话题回复统一挂到根消息上
+	if msg.ThreadID != 0 {
+		root, ok := threadRoot(msg.To, msg.ThreadID, msg.From)
+		if !ok {
+			sendError(user, "unknown_thread", "话题不存在")
+			return
+		}
+		msg.ThreadID = root
+	}
+
+	// 回复消息内嵌原消息片段，客户端无需再次查询
+	if code, text := resolveQuotes(&msg); code != "" {
+		sendError(user, code, text)
+		return
+	}
 
-		// 填充消息信息
-		msgMu.Lock()
-		msg.ID = msgID
-		msgID++
-		msg.Timestamp = time.Now()
-		msg.IsRead = false
+	if s.Archived && !reviveSession(msg.To) {
+		sendError(user, "session_archived", "会话已归档，无法发送消息")
+		return
+	}
+
+	// 填充消息信息
+	msgMu.Lock()
+	msg.ID = allocMessageIDLocked()
+	msg.Timestamp = time.Now()
+	msg.UpdatedAt = msg.Timestamp
+	msg.Deleted = false
+	msg.IsRead = false
+	msg.ReadBy = nil
+	msg.DeliveredTo = nil
+	msg.IsSystem = false
+	msg.Priority = PriorityNormal
+	msg.Key, msg.Args = "", nil
+	msg.SeenCount = 0
+	msg.Preview = nil
+	msg.Avatar = ""
+	if avatarsEnabled {
 		msg.Avatar = string(msg.From[0])
-		messages = append(messages, msg)
-		msgMu.Unlock()
-
-		// 更新会话最后一条消息
-		for i, s := range sessions {
-			if s.ID == msg.To {
-				sessions[i].LastMsg = msg.Content
-				sessions[i].LastTime = msg.Timestamp
-				break
+	}
+	messages = append(messages, msg)
+	trackMessagesLocked(msg)
+	if msg.Streaming {
+		streamRaw[msg.ID] = raw
+	}
+	persistLocked(msg)
+	msgMu.Unlock()
+	flushStore()
+
+	rememberSend(msg)
+	recordSend(msg.From, msg.To, msg.Timestamp)
+	countMessage(msg)
+	saveDraft(msg.From, msg.To, "")
+
+	// 更新会话最后一条消息（悄悄话与不进主频道的话题回复不出现在会话预览中）
+	if len(msg.Whisper) == 0 && !hiddenFromChannel(msg) {
+		touchSession(msg.To, msg.Content, msg.Timestamp)
+	}
+	// 发出消息即结束输入状态
+	stopTyping(userSessionKey{user: msg.From, session: msg.To})
+
+	// 广播消息，链接预览就绪后另行推送
+	broadcast(msg)
+	schedulePreview(msg)
+	// 回发给发送者，多端在线时同步到其他连接
+	user.enqueue(msg)
+	for _, u := range userConns(user.Username) {
+		if u != user {
+			u.enqueue(msg)
+		}
+	}
+}
+
+// 会话列表：GET 获取列表，POST 创建群组
+func sessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		createSessionHandler(w, r)
+		return
+	}
+	offset, limit, ok := parseSessionPage(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	list := sessionDetails(listSessions())
+	if username := r.URL.Query().Get("user"); username != "" {
+		list = applyUserOrder(username, list)
+	}
+	// 会话过多或响应过大时分页，X-Next-Offset 给出下一页的 offset
+	page, next := pageSessions(list, offset, limit)
+	if next >= 0 {
+		w.Header().Set("X-Next-Offset", strconv.Itoa(next))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(list)))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// 为会话补充消息数、成员数与基于实际消息的最后活跃时间（单次遍历消息）
+func sessionDetails(list []Session) []SessionDetail {
+	type stat struct {
+		count int
+		last  time.Time
+	}
+	stats := make(map[string]*stat, len(list))
+	for _, s := range list {
+		stats[s.ID] = &stat{}
+	}
+	msgMu.Lock()
+	for _, msg := range messages {
+		if st, ok := stats[msg.To]; ok && !msg.Deleted && len(msg.Whisper) == 0 {
+			st.count++
+			if msg.Timestamp.After(st.last) {
+				st.last = msg.Timestamp
 			}
 		}
+	}
+	msgMu.Unlock()
 
-		// 广播消息
-		broadcast(msg)
-		// 回发给发送者
-		_ = websocket.JSON.Send(ws, msg)
+	res := make([]SessionDetail, 0, len(list))
+	for _, s := range list {
+		st := stats[s.ID]
+		if st.count > 0 {
+			s.LastTime = st.last
+		}
+		res = append(res, SessionDetail{Session: s, MessageCount: st.count, MemberCount: len(sessionMembers(s.ID))})
 	}
+	return res
 }
 
-// 获取会话列表
-func sessionsHandler(w http.ResponseWriter, r *http.Request) {
+// 单个会话：GET 获取详情，PATCH 修改属性（管理员）
+func sessionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getSessionHandler(w, r)
+	case http.MethodPatch:
+		patchSessionHandler(w, r)
+	case http.MethodDelete:
+		deleteSessionHandler(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// 获取单个会话详情：GET /api/session?session_id=[&user=]
+func getSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s, ok := getSession(sessionID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	detail := sessionDetails([]Session{s})[0]
+	// 提供 user 时给出该用户在会话中的未读数
+	if username := r.URL.Query().Get("user"); username != "" {
+		for _, msg := range unreadMessages(username) {
+			if msg.To == sessionID {
+				detail.Unread++
+			}
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(sessions)
+	_ = json.NewEncoder(w).Encode(detail)
 }
 
-// 获取历史消息
-func messagesHandler(w http.ResponseWriter, r *http.Request) {
+// 修改会话属性（管理员）
+func patchSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
 	sessionID := r.URL.Query().Get("session_id")
 	if sessionID == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	var patch struct {
+		Archived      *bool `json:"archived"`
+		SlowMode      *int  `json:"slow_mode"`
+		RetentionDays *int  `json:"retention_days"` // 负数表示恢复继承全局设置
+	}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sessMu.Lock()
+	s, ok := sessions[sessionID]
+	if ok && patch.Archived != nil {
+		s.Archived = *patch.Archived
+		s.AutoArchived = false
+	}
+	if ok && patch.SlowMode != nil && *patch.SlowMode >= 0 {
+		s.SlowMode = *patch.SlowMode
+	}
+	if ok && patch.RetentionDays != nil {
+		if days := *patch.RetentionDays; days < 0 {
+			s.RetentionDays = nil
+		} else {
+			s.RetentionDays = &days
+		}
+	}
+	var res Session
+	if ok {
+		res = *s
+	}
+	sessMu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	detail, _ := json.Marshal(patch)
+	audit(adminActor(r), "update_session", sessionID, string(detail))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// 历史消息分页响应
+type MessagePage struct {
+	Messages    []Message `json:"messages"`
+	NextBefore  *int64    `json:"next_before"`            // 下一页游标，没有更早的消息时为 null
+	LastReadID  *int64    `json:"last_read_id,omitempty"` // 查看者最后已读的消息 ID
+	MemberCount int       `json:"member_count,omitempty"` // 会话当前成员数，仅在 seen=1 时填充
+}
+
+// 单页历史消息的最大条数，0 表示不分页
+var maxHistoryPage = envInt("MAX_HISTORY_PAGE", 50)
+
+// 获取历史消息：默认返回分页信封，legacy=1 时按旧格式返回全部消息数组
+func messagesHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	viewer := r.URL.Query().Get("user") // 查看者，用于过滤悄悄话
+	legacy := r.URL.Query().Get("legacy") == "1"
+	seen := r.URL.Query().Get("seen") == "1" // 以已读人数代替完整的已读列表
+	desc, byTime, ok := parseOrder(r)
+	since, delta, sinceOK := parseUpdatedSince(r)
+	before, limit, pageOK := parsePage(r, maxHistoryPage, maxHistoryPage)
+	from, to, rangeOK := parseTimeRange(r)
+	if sessionID == "" || !ok || !sinceOK || !pageOK || !rangeOK {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	msgMu.Lock()
-	var res []Message
+	res := make([]Message, 0) // 无消息时输出 [] 而不是 null
 	for _, msg := range messages {
-		if msg.To == sessionID {
+		if msg.To != sessionID || !visibleTo(msg, viewer) || hiddenFromChannel(msg) || !inTimeRange(msg.Timestamp, from, to) {
+			continue
+		}
+		if !legacy && before > 0 && msg.ID >= before {
+			continue
+		}
+		// 增量同步返回修改过的消息（含删除墓碑），否则只返回未删除的消息
+		if delta {
+			if msg.UpdatedAt.After(since) {
+				res = append(res, msg)
+			}
+		} else if !msg.Deleted {
 			res = append(res, msg)
 		}
 	}
 	msgMu.Unlock()
 
+	withReadState(res)
+	if seen {
+		for i := range res {
+			res[i].SeenCount = len(res[i].ReadBy)
+			res[i].ReadBy = nil
+		}
+	}
+
+	// 提供查看者的最后已读位置，客户端据此绘制“新消息”分隔线
+	var lastRead *int64
+	if viewer != "" {
+		id := lastReadID(viewer, sessionID)
+		lastRead = &id
+		w.Header().Set("X-Last-Read-ID", strconv.FormatInt(id, 10))
+	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(res)
+
+	if legacy {
+		sortMessages(res, desc, byTime)
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+
+	// 取 before 之前最新的 limit 条，还有更早消息时给出下一页游标
+	page := MessagePage{Messages: res, LastReadID: lastRead}
+	if seen {
+		page.MemberCount = len(sessionMembers(sessionID))
+	}
+	if limit > 0 && len(res) > limit {
+		page.Messages = res[len(res)-limit:]
+		next := page.Messages[0].ID
+		page.NextBefore = &next
+	}
+	sortMessages(page.Messages, desc, byTime)
+	_ = json.NewEncoder(w).Encode(page)
 }
 
 // 首页
@@ -165,11 +843,47 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	// 加载持久化的消息、封禁列表与审计日志
+	if err := initStore(); err != nil {
+		log.Fatalf("初始化消息存储失败: %v", err)
+	}
+	loadBans()
+	loadAudit()
+
 	// 路由
 	http.HandleFunc("/", indexHandler)
 	http.Handle("/ws", websocket.Handler(wsHandler))
 	http.HandleFunc("/api/sessions", sessionsHandler)
-	http.HandleFunc("/api/messages", messagesHandler)
+	http.HandleFunc("/api/sessions/clear", clearHistoryHandler)
+	http.HandleFunc("/api/sessions/restore", restoreSessionHandler)
+	http.HandleFunc("/api/sessions/avatar", sessionAvatarHandler)
+	http.HandleFunc("/api/session", sessionHandler)
+	http.HandleFunc("/api/members", membersHandler)
+	http.HandleFunc("/api/messages", limitHeavy(messagesHandler))
+	http.HandleFunc("/api/messages/by-id", messagesByIDHandler)
+	http.HandleFunc("/api/thread", threadHandler)
+	http.HandleFunc("/api/search", searchHandler)
+	http.HandleFunc("/api/unread", unreadHandler)
+	http.HandleFunc("/api/read-all", readAllHandler)
+	http.HandleFunc("/api/badge", badgeHandler)
+	http.HandleFunc("/api/export", limitHeavy(exportHandler))
+	http.HandleFunc("/api/profile", profileHandler)
+	http.HandleFunc("/api/users/search", userSearchHandler)
+	http.HandleFunc("/api/health", healthHandler)
+	http.HandleFunc("/api/avatar", avatarHandler)
+	http.HandleFunc("/api/draft", draftHandler)
+	http.HandleFunc("/api/announce", announceHandler)
+	http.HandleFunc("/api/admin/bans", bansHandler)
+	http.HandleFunc("/api/admin/kick", kickHandler)
+	http.HandleFunc("/api/admin/import", importHandler)
+	http.HandleFunc("/api/admin/audit", auditHandler)
+	http.HandleFunc("/api/admin/reports", reportsHandler)
+	http.HandleFunc("/api/admin/user-stats", userStatsHandler)
+	http.HandleFunc("/api/admin/user-export", userExportHandler)
+	http.HandleFunc("/api/admin/metrics", metricsHandler)
+	http.HandleFunc("/api/admin/dead-letters", deadLettersHandler)
+	http.HandleFunc("/api/admin/maintenance", maintenanceHandler)
+	http.HandleFunc("/api/admin/refresh", refreshHandler)
 
 	// 端口适配
 	port := os.Getenv("PORT")
@@ -177,6 +891,26 @@ func main() {
 		port = "3000"
 	}
 
+	// 收到退出信号后停止后台任务并优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go reapIdle(ctx)
+	go purgeDeletedSessions(ctx)
+	go sweepRetention(ctx)
+	go sweepInactiveSessions(ctx)
+	watchMaintenanceSignal()
+
+	srv := &http.Server{Addr: ":" + port, Handler: maintenanceGate(http.DefaultServeMux)}
+	go func() {
+		<-ctx.Done()
+		closeAll(CloseGoingAway, "server shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
 	log.Printf("服务启动在 http://localhost:%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
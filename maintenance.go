@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// 维护模式：开启后拒绝所有写操作，读取接口不受影响
+var maintenance atomic.Bool
+
+// 维护模式下被拒绝的帧类型：凡是会修改消息、会话或用户数据的帧都在此列，
+// 只有在线状态、输入状态等不落盘的帧与只读查询不受影响；ack 放行，
+// 否则客户端会不断重发确认，送达状态在维护期间照常记录
+var writeFrames = map[string]bool{
+	"":         true,
+	"message":  true,
+	"whisper":  true,
+	"forward":  true,
+	"move":     true,
+	"append":   true,
+	"complete": true,
+	"pin":      true,
+	"unpin":    true,
+	"read":     true,
+	"join":     true,
+	"snooze":   true,
+	"report":   true,
+
+	"set_prefs":          true,
+	"save_draft":         true,
+	"clear_draft":        true,
+	"reorder_sessions":   true,
+	"delete_my_messages": true,
+}
+
+// 维护期间拒绝除维护开关本身以外的所有 HTTP 写请求
+func maintenanceGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if maintenance.Load() && r.URL.Path != "/api/admin/maintenance" {
+				http.Error(w, "服务维护中", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 切换维护模式并通知所有在线连接
+func setMaintenance(on bool) {
+	if maintenance.Swap(on) == on {
+		return
+	}
+	log.Printf("维护模式: %v", on)
+	userMu.Lock()
+	defer userMu.Unlock()
+	for _, conns := range users {
+		for u := range conns {
+			u.enqueue(Event{Type: "maintenance", Data: on})
+		}
+	}
+}
+
+// 收到 SIGUSR1 时切换维护模式
+func watchMaintenanceSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		for range ch {
+			setMaintenance(!maintenance.Load())
+		}
+	}()
+}
+
+// 维护模式管理（管理员）：GET 查看，POST 设置
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		setMaintenance(req.Enabled)
+		action := "maintenance_off"
+		if req.Enabled {
+			action = "maintenance_on"
+		}
+		audit(adminActor(r), action, "", "")
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]bool{"enabled": maintenance.Load()})
+}
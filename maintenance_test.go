@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func enableMaintenance(t *testing.T) {
+	t.Helper()
+	maintenance.Store(true)
+	t.Cleanup(func() { maintenance.Store(false) })
+}
+
+func TestMaintenanceBlocksWriteFrames(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	m := seedMessage(Message{From: "bob", To: "room", Content: "hi"})
+	alice := connect(t, "alice")
+	enableMaintenance(t)
+
+	frames := []map[string]interface{}{
+		{"type": "read", "session_id": "room", "id": m.ID},
+		{"type": "save_draft", "session_id": "room", "content": "x"},
+		{"type": "reorder_sessions", "order": []string{"room"}},
+		{"type": "snooze", "session_id": "room", "minutes": 5},
+		{"type": "report", "id": m.ID, "reason": "spam"},
+	}
+	for _, f := range frames {
+		alice.send(f)
+		var info ErrorInfo
+		_ = json.Unmarshal(alice.event("error"), &info)
+		if info.Code != "maintenance" {
+			t.Fatalf("%s: got %+v", f["type"], info)
+		}
+	}
+	if readMark("alice", "room") != 0 {
+		t.Fatal("read mark advanced during maintenance")
+	}
+}
+
+func TestMaintenanceBlocksHTTPWrites(t *testing.T) {
+	resetState(t)
+	h := maintenanceGate(http.HandlerFunc(sessionsHandler))
+	enableMaintenance(t)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/sessions?user=alice", strings.NewReader(`{"name":"x"}`)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("POST got %d", w.Code)
+	}
+	if len(listSessions()) != 0 {
+		t.Fatal("session created during maintenance")
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET got %d", w.Code)
+	}
+}
+
+// 维护期间 ack 照常处理，发送者收到送达回执
+func TestMaintenanceAllowsAck(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	m := seedMessage(Message{From: "bob", To: "room", Content: "hi"})
+	alice := connect(t, "alice")
+	bob := connect(t, "bob")
+	enableMaintenance(t)
+
+	alice.send(map[string]interface{}{"type": "ack", "id": m.ID})
+	var ev DeliveredEvent
+	_ = json.Unmarshal(bob.event("delivered"), &ev)
+	if ev.ID != m.ID || ev.User != "alice" {
+		t.Fatalf("got %+v", ev)
+	}
+	alice.noEvent("error", 100*time.Millisecond)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// 会话内角色
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// 用户-会话组合键
+type userSessionKey struct {
+	user, session string
+}
+
+// 会话成员表：会话 ID -> 用户名集合，由 sessMu 保护
+var members = make(map[string]map[string]bool)
+
+// 加入会话
+func addMember(sessionID, username string) {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	addMemberLocked(sessionID, username)
+}
+
+func addMemberLocked(sessionID, username string) {
+	m, ok := members[sessionID]
+	if !ok {
+		m = make(map[string]bool)
+		members[sessionID] = m
+	}
+	m[username] = true
+}
+
+// 判断是否为会话成员，公开会话首次访问时自动加入
+func ensureMember(sessionID, username string) bool {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	if members[sessionID][username] {
+		return true
+	}
+	s, ok := sessions[sessionID]
+	if !ok || !s.Public || s.DeletedAt != nil {
+		return false
+	}
+	addMemberLocked(sessionID, username)
+	return true
+}
+
+// 返回会话成员集合的副本
+func sessionMembers(sessionID string) map[string]bool {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	res := make(map[string]bool, len(members[sessionID]))
+	for name := range members[sessionID] {
+		res[name] = true
+	}
+	return res
+}
+
+// 加入所有公开会话
+func joinPublicSessions(username string) {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	for id, s := range sessions {
+		if s.Public && s.DeletedAt == nil {
+			addMemberLocked(id, username)
+		}
+	}
+}
+
+// 返回用户加入的会话集合，已删除（等待清理）的会话不计入
+func userSessions(username string) map[string]bool {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	res := make(map[string]bool)
+	for id, m := range members {
+		if s, ok := sessions[id]; ok && s.DeletedAt == nil && m[username] {
+			res[id] = true
+		}
+	}
+	return res
+}
+
+// 会话成员信息
+type MemberInfo struct {
+	Username string `json:"username"`
+	Avatar   string `json:"avatar,omitempty"`
+	Role     string `json:"role"`
+	Online   bool   `json:"online"`
+}
+
+// 成员在会话中的角色：会话创建者与全局管理员为 admin
+func memberRole(s Session, username string) string {
+	if username == s.Owner || isAdminUser(username) {
+		return RoleAdmin
+	}
+	return RoleMember
+}
+
+// 会话成员列表：GET /api/members?session_id=&user=，仅会话成员可查看
+func membersHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	username := r.URL.Query().Get("user")
+	if sessionID == "" || username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s, ok := getSession(sessionID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	joined := sessionMembers(sessionID)
+	if !joined[username] {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	names := make([]string, 0, len(joined))
+	for name := range joined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	userMu.Lock()
+	online := make(map[string]bool, len(names))
+	for _, name := range names {
+		online[name] = len(users[name]) > 0
+	}
+	userMu.Unlock()
+
+	res := make([]MemberInfo, 0, len(names))
+	for _, name := range names {
+		info := MemberInfo{Username: name, Role: memberRole(s, name), Online: online[name]}
+		if avatarsEnabled {
+			_, info.Avatar = identityResolver.Resolve(name)
+		}
+		res = append(res, info)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMemberSendReachesOnlyMembers(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	alice := connect(t, "alice")
+	bob := connect(t, "bob")
+	carol := connect(t, "carol")
+
+	alice.send(map[string]string{"to": "room", "content": "members only"})
+	if got := bob.message(); got.Content != "members only" {
+		t.Fatalf("bob got %+v", got)
+	}
+	carol.noEvent("", 100*time.Millisecond) // 聊天消息帧没有 type 字段
+}
+
+func TestNonMemberSendRejected(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	mallory := connect(t, "mallory")
+
+	mallory.send(map[string]string{"to": "room", "content": "let me in"})
+	var info ErrorInfo
+	_ = json.Unmarshal(mallory.event("error"), &info)
+	if info.Code != "not_member" {
+		t.Fatalf("got %+v", info)
+	}
+	if sessionMembers("room")["mallory"] || len(recentMessages("room", "alice", 10)) != 0 {
+		t.Fatal("non-member message accepted")
+	}
+}
+
+// 连接后才创建的公开会话：首次发送时自动加入
+func TestPublicRoomAutoJoinOnSend(t *testing.T) {
+	resetState(t)
+	alice := connect(t, "alice")
+	addSession(Session{ID: "lobby", Name: "lobby", IsGroup: true, Public: true})
+
+	alice.send(map[string]string{"to": "lobby", "content": "hello lobby"})
+	if got := alice.message(); got.To != "lobby" {
+		t.Fatalf("alice got %+v", got)
+	}
+	if !sessionMembers("lobby")["alice"] {
+		t.Fatal("alice not enrolled in the public room")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import "log"
+
+// 内存中消息的总大小上限（近似字节数），0 表示不限制；超出时淘汰最早的消息，持久化存储中的副本保留。
+// 未配置存储时内存即唯一副本，不做淘汰
+var maxMemoryBytes = envInt("MAX_MEMORY_BYTES", 0)
+
+// 单条消息的固定开销估算（结构体、切片头与 map 节点）
+const messageOverhead = 256
+
+// 内存中消息的近似总大小，受 msgMu 保护；仅在追加时累加，原地修改与删除在淘汰前重新统计
+var messageBytes int
+
+// 估算单条消息占用的内存
+func messageSize(msg Message) int {
+	n := messageOverhead + len(msg.From) + len(msg.To) + len(msg.Content) + len(msg.Avatar) + len(msg.Key)
+	for _, s := range msg.ReadBy {
+		n += len(s)
+	}
+	for _, s := range msg.DeliveredTo {
+		n += len(s)
+	}
+	for _, s := range msg.Whisper {
+		n += len(s)
+	}
+	for _, s := range msg.Args {
+		n += len(s)
+	}
+	for _, q := range append([]*QuotedSnippet{msg.Quoted}, msg.Quotes...) {
+		if q != nil {
+			n += len(q.From) + len(q.Content)
+		}
+	}
+	if p := msg.Preview; p != nil {
+		n += len(p.URL) + len(p.Title) + len(p.Description) + len(p.Image)
+	}
+	for k, v := range msg.Meta {
+		n += len(k) + len(v)
+	}
+	return n + 8*len(msg.ReplyToIDs)
+}
+
+// 记录新追加的消息并在超出上限时淘汰，调用方需持有 msgMu
+func trackMessagesLocked(list ...Message) {
+	if maxMemoryBytes <= 0 || store == nil {
+		return
+	}
+	for _, msg := range list {
+		messageBytes += messageSize(msg)
+	}
+	if messageBytes > maxMemoryBytes {
+		evictMessagesLocked()
+	}
+}
+
+// 重新统计实际大小，从最早的消息开始淘汰到上限的九成，避免每条新消息都触发淘汰
+func evictMessagesLocked() {
+	total := 0
+	for _, msg := range messages {
+		total += messageSize(msg)
+	}
+	target := maxMemoryBytes / 10 * 9
+	n := 0
+	for n < len(messages) && total > target {
+		total -= messageSize(messages[n])
+		n++
+	}
+	if n > 0 {
+		// 复制到新切片，释放被淘汰消息占用的底层数组
+		messages = append([]Message(nil), messages[n:]...)
+		log.Printf("内存超出上限，淘汰最早的消息: count=%d bytes=%d", n, total)
+	}
+	messageBytes = total
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// 设置内存上限，测试结束后恢复
+func withMemoryLimit(t *testing.T, n int) {
+	t.Helper()
+	old, oldBytes := maxMemoryBytes, messageBytes
+	maxMemoryBytes, messageBytes = n, 0
+	t.Cleanup(func() { maxMemoryBytes, messageBytes = old, oldBytes })
+}
+
+func TestEvictionSkippedWithoutStore(t *testing.T) {
+	resetState(t)
+	withMemoryLimit(t, 4*messageOverhead)
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+
+	for i := 0; i < 10; i++ {
+		alice.send(map[string]interface{}{"to": "room", "content": fmt.Sprintf("hello %d", i)})
+		alice.message()
+	}
+	if got := messageCount(); got != 10 {
+		t.Fatalf("%d messages in memory, want all 10 kept without a store", got)
+	}
+}
+
+func TestEvictedMessagesRemainInStore(t *testing.T) {
+	resetState(t)
+	withMemoryLimit(t, 4*messageOverhead)
+	fs := &fileStore{path: filepath.Join(t.TempDir(), "messages.jsonl")}
+	store = fs
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+
+	for i := 0; i < 10; i++ {
+		alice.send(map[string]interface{}{"to": "room", "content": fmt.Sprintf("hello %d", i)})
+		alice.message()
+	}
+	if got := messageCount(); got >= 10 {
+		t.Fatalf("%d messages in memory, expected eviction", got)
+	}
+	loaded, err := fs.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 10 {
+		t.Fatalf("store has %d messages, want 10", len(loaded))
+	}
+	for i, m := range loaded {
+		if m.ID != int64(i+1) {
+			t.Fatalf("store message %d has id %d", i, m.ID)
+		}
+	}
+}
+
+func messageCount() int {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	return len(messages)
+}
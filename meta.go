@@ -0,0 +1,19 @@
+package main
+
+// 客户端元数据的总大小上限（键与值的字节数之和），0 表示不限制
+var maxMetaBytes = envInt("MAX_META_BYTES", 1024)
+
+// 检查客户端元数据大小，超出时返回错误码与提示
+func checkMeta(meta map[string]string) (code, text string) {
+	if maxMetaBytes <= 0 {
+		return "", ""
+	}
+	n := 0
+	for k, v := range meta {
+		n += len(k) + len(v)
+	}
+	if n > maxMetaBytes {
+		return "meta_too_large", "消息元数据过大"
+	}
+	return "", ""
+}
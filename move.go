@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// 消息移动事件内容：目标会话据此插入消息，移动话题根消息时一并包含其回复
+type MovedEvent struct {
+	FromSession string    `json:"from_session"`
+	Messages    []Message `json:"messages"`
+}
+
+// 将消息（及以它为根的话题回复）移到另一会话，保留内容、作者与 ID；
+// 单独移动的回复脱离原话题。返回被移动的消息（按 ID 升序）与原会话
+func moveMessage(id int64, to string) ([]Message, string, string) {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	idx := -1
+	for i, m := range messages {
+		if m.ID == id && !m.Deleted {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, "", "not_found"
+	}
+	root := &messages[idx]
+	if len(root.Whisper) > 0 {
+		return nil, "", "whisper"
+	}
+	from := root.To
+	if from == to {
+		return nil, from, "same_session"
+	}
+
+	now := time.Now()
+	var moved []Message
+	for i := range messages {
+		m := &messages[i]
+		if m.To != from || (m.ID != id && (root.ThreadID != 0 || m.ThreadID != id)) {
+			continue
+		}
+		m.To = to
+		if m.ID == id {
+			m.ThreadID = 0
+		}
+		m.UpdatedAt = now
+		moved = append(moved, *m)
+	}
+	persistLocked(moved...)
+	return moved, from, ""
+}
+
+// 按会话中剩余的最后一条可见消息更新预览，没有消息时清空
+func refreshSessionLast(sessionID string) {
+	var last Message
+	msgMu.Lock()
+	for _, m := range messages {
+		if m.To == sessionID && !m.Deleted && len(m.Whisper) == 0 && !hiddenFromChannel(m) {
+			last = m
+		}
+	}
+	msgMu.Unlock()
+
+	sessMu.Lock()
+	if s, ok := sessions[sessionID]; ok {
+		if last.ID != 0 {
+			s.LastMsg, s.LastTime = last.Content, last.Timestamp
+		} else {
+			s.LastMsg = ""
+		}
+	}
+	sessMu.Unlock()
+}
+
+// 向会话成员推送移动事件，每个接收者只收到自己可见的消息
+func broadcastMoved(sessionID, from string, moved []Message) {
+	recipients := sessionMembers(sessionID)
+	runInSession(sessionID, func() { deliverMoved(recipients, from, moved) })
+}
+
+func deliverMoved(recipients map[string]bool, from string, moved []Message) {
+	userMu.Lock()
+	defer userMu.Unlock()
+	for name, conns := range users {
+		if !recipients[name] {
+			continue
+		}
+		var visible []Message
+		for _, m := range moved {
+			if visibleTo(m, name) {
+				visible = append(visible, m)
+			}
+		}
+		if len(visible) == 0 {
+			continue
+		}
+		for u := range conns {
+			u.enqueue(Event{Type: "message_moved", Data: MovedEvent{FromSession: from, Messages: visible}})
+		}
+	}
+}
+
+// 处理 move 帧：管理员把跑题的消息移到合适的会话，悄悄话不可移动
+func handleMove(user *User, data []byte) {
+	var req struct {
+		ID int64  `json:"id"`
+		To string `json:"to"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil || req.ID == 0 || req.To == "" {
+		sendError(user, "bad_frame", "无法解析的移动请求")
+		return
+	}
+	if !user.verifiedAdmin() {
+		sendError(user, "forbidden", "只有通过认证的管理员可以移动消息")
+		return
+	}
+	target, ok := getSession(req.To)
+	if !ok {
+		sendError(user, "unknown_session", "会话不存在")
+		return
+	}
+
+	moved, from, code := moveMessage(req.ID, req.To)
+	switch code {
+	case "not_found":
+		sendError(user, "not_found", "消息不存在")
+		return
+	case "whisper":
+		sendError(user, "forbidden", "悄悄话不能移动")
+		return
+	case "same_session":
+		return
+	}
+	flushStore()
+
+	ids := make([]int64, len(moved))
+	for i, m := range moved {
+		ids[i] = m.ID
+		if unpinMessage(from, m.ID) {
+			broadcastEvent(from, Event{Type: "unpinned", Data: PinEvent{SessionID: from, MessageID: m.ID, By: user.Username}})
+		}
+	}
+	refreshSessionLast(from)
+	for _, m := range moved {
+		if len(m.Whisper) == 0 && !hiddenFromChannel(m) && m.Timestamp.After(target.LastTime) {
+			touchSession(req.To, m.Content, m.Timestamp)
+			target.LastTime = m.Timestamp
+		}
+	}
+	audit(user.Username, "move_message", strconv.FormatInt(req.ID, 10), from+" -> "+req.To)
+
+	broadcastEvent(from, Event{Type: "messages_deleted", Data: DeletedEvent{SessionID: from, IDs: ids}})
+	broadcastMoved(req.To, from, moved)
+	user.enqueue(Event{Type: "moved", Data: MovedEvent{FromSession: from, Messages: moved}})
+}
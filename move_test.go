@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 启用挑战认证并以管理员身份连接
+func connectAdmin(t *testing.T, name string) *testClient {
+	t.Helper()
+	secret := authSecret
+	authSecret = "test-secret"
+	t.Cleanup(func() { authSecret = secret })
+	t.Setenv("ADMIN_USERS", name)
+
+	server, client := newPipeTransport()
+	go serveConn(server, "127.0.0.1")
+	c := &testClient{t: t, name: name, tr: client, frames: make(chan []byte, 256)}
+	go func() {
+		for {
+			data, err := client.Receive()
+			if err != nil {
+				close(c.frames)
+				return
+			}
+			c.frames <- data
+		}
+	}()
+	t.Cleanup(func() { _ = client.Close(CloseNormal, "") })
+	var ch Challenge
+	_ = json.Unmarshal(c.event("challenge"), &ch)
+	c.send(map[string]string{"username": name, "auth": challengeResponse(ch.Nonce, name)})
+	c.event("hello")
+	return c
+}
+
+// 直接写入一条历史消息
+func seedMessage(m Message) Message {
+	msgMu.Lock()
+	m.ID = msgID
+	msgID++
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+	messages = append(messages, m)
+	msgMu.Unlock()
+	if len(m.Whisper) == 0 && m.ThreadID == 0 {
+		touchSession(m.To, m.Content, m.Timestamp)
+	}
+	return m
+}
+
+func sessionOf(id int64) string {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	for _, m := range messages {
+		if m.ID == id {
+			return m.To
+		}
+	}
+	return ""
+}
+
+func TestMoveMessageToAnotherSession(t *testing.T) {
+	resetState(t)
+	newTestSession("general", true, "admin", "alice")
+	newTestSession("offtopic", true, "admin", "bob")
+	first := seedMessage(Message{From: "alice", To: "general", Content: "on topic", Timestamp: time.Now().Add(-time.Minute)})
+	stray := seedMessage(Message{From: "alice", To: "general", Content: "football?"})
+	bob := connect(t, "bob") // 在启用挑战认证之前连接
+	admin := connectAdmin(t, "admin")
+
+	admin.send(map[string]interface{}{"type": "move", "id": stray.ID, "to": "offtopic"})
+	var ev MovedEvent
+	_ = json.Unmarshal(bob.event("message_moved"), &ev)
+	if ev.FromSession != "general" || len(ev.Messages) != 1 || ev.Messages[0].ID != stray.ID {
+		t.Fatalf("bob got %+v", ev)
+	}
+	if got := sessionOf(stray.ID); got != "offtopic" {
+		t.Fatalf("message is in %q", got)
+	}
+	src, _ := getSession("general")
+	if src.LastMsg != first.Content {
+		t.Fatalf("source preview %q, want %q", src.LastMsg, first.Content)
+	}
+	dst, _ := getSession("offtopic")
+	if dst.LastMsg != stray.Content {
+		t.Fatalf("target preview %q", dst.LastMsg)
+	}
+}
+
+func TestMoveThreadRootTakesReplies(t *testing.T) {
+	resetState(t)
+	newTestSession("general", true, "admin")
+	newTestSession("offtopic", true, "admin")
+	root := seedMessage(Message{From: "admin", To: "general", Content: "root"})
+	reply := seedMessage(Message{From: "admin", To: "general", Content: "reply", ThreadID: root.ID})
+	admin := connectAdmin(t, "admin")
+
+	admin.send(map[string]interface{}{"type": "move", "id": root.ID, "to": "offtopic"})
+	var ev MovedEvent
+	_ = json.Unmarshal(admin.event("moved"), &ev)
+	if len(ev.Messages) != 2 {
+		t.Fatalf("moved %+v", ev.Messages)
+	}
+	if got := sessionOf(reply.ID); got != "offtopic" {
+		t.Fatalf("reply left in %q", got)
+	}
+	if src, _ := getSession("general"); src.LastMsg != "" {
+		t.Fatalf("source preview %q after moving everything", src.LastMsg)
+	}
+}
+
+func TestMoveRefusesWhisper(t *testing.T) {
+	resetState(t)
+	newTestSession("general", true, "admin", "alice")
+	newTestSession("offtopic", true, "admin", "bob")
+	w := seedMessage(Message{From: "alice", To: "general", Content: "psst", Whisper: []string{"admin"}})
+	bob := connect(t, "bob") // 在启用挑战认证之前连接
+	admin := connectAdmin(t, "admin")
+
+	admin.send(map[string]interface{}{"type": "move", "id": w.ID, "to": "offtopic"})
+	var info ErrorInfo
+	_ = json.Unmarshal(admin.event("error"), &info)
+	if info.Code != "forbidden" {
+		t.Fatalf("got %+v", info)
+	}
+	bob.noEvent("message_moved", 100*time.Millisecond)
+	if got := sessionOf(w.ID); got != "general" {
+		t.Fatalf("whisper moved to %q", got)
+	}
+}
+
+func TestMoveRequiresAuthenticatedAdmin(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_USERS", "admin")
+	newTestSession("general", true, "admin")
+	newTestSession("offtopic", true, "admin")
+	m := seedMessage(Message{From: "admin", To: "general", Content: "hi"})
+	// 未启用 AUTH_SECRET 时用户名是自报的，不能凭此获得管理权限
+	impostor := connect(t, "admin")
+
+	impostor.send(map[string]interface{}{"type": "move", "id": m.ID, "to": "offtopic"})
+	var info ErrorInfo
+	_ = json.Unmarshal(impostor.event("error"), &info)
+	if info.Code != "forbidden" {
+		t.Fatalf("got %+v", info)
+	}
+	if got := sessionOf(m.ID); got != "general" {
+		t.Fatalf("message moved to %q", got)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// 线路编码名称
+const (
+	encodingJSON    = "json"
+	encodingMsgpack = "msgpack"
+)
+
+// MessagePack 数据不完整（分片尚未收齐）
+var errShortMsgpack = errors.New("msgpack: unexpected end of data")
+
+// 将 JSON 文本转为 MessagePack，复用现有的 JSON 序列化规则（字段名、omitempty 等）
+func jsonToMsgpack(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // 保留 int64 消息 ID 的精度
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeMsgpack(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 将 MessagePack 数据转为 JSON 文本，供帧分发逻辑统一处理
+func msgpackToJSON(data []byte) ([]byte, error) {
+	v, rest, err := decodeMsgpack(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("msgpack: trailing data")
+	}
+	return json.Marshal(v)
+}
+
+// 编码 JSON 解码得到的通用值
+func encodeMsgpack(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			encodeInt(buf, n)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		encodeLen(buf, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []interface{}:
+		encodeLen(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, e := range v {
+			if err := encodeMsgpack(buf, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys) // 输出稳定，便于比对
+		encodeLen(buf, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			_ = encodeMsgpack(buf, k)
+			if err := encodeMsgpack(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return nil
+}
+
+// 按长度选择紧凑格式（fix 前缀）或 8/16/32 位长度格式，code8 为 0 表示该类型没有 8 位格式
+func encodeLen(buf *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n < fixMax:
+		buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(code8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func encodeInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		buf.WriteByte(byte(n))
+	case n >= 0 && n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n >= 0 && n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	case n >= 0 && n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	case n >= 0:
+		buf.WriteByte(0xcf)
+		_ = binary.Write(buf, binary.BigEndian, uint64(n))
+	case n >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// 解码一个 MessagePack 值，返回剩余数据；数据不足时返回 errShortMsgpack
+func decodeMsgpack(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errShortMsgpack
+	}
+	c, data := data[0], data[1:]
+	switch {
+	case c <= 0x7f:
+		return int64(c), data, nil
+	case c >= 0xe0:
+		return int64(int8(c)), data, nil
+	case c&0xf0 == 0x80:
+		return decodeMap(data, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return decodeArray(data, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		return decodeStr(data, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, data, nil
+	case 0xc2:
+		return false, data, nil
+	case 0xc3:
+		return true, data, nil
+	case 0xc4, 0xc5, 0xc6: // bin，按 JSON 惯例转为 base64 字符串
+		n, rest, err := readUint(data, 1<<(c-0xc4))
+		if err != nil {
+			return nil, nil, err
+		}
+		if uint64(len(rest)) < n {
+			return nil, nil, errShortMsgpack
+		}
+		return append([]byte(nil), rest[:n]...), rest[n:], nil
+	case 0xca:
+		n, rest, err := readUint(data, 4)
+		return float64(math.Float32frombits(uint32(n))), rest, err
+	case 0xcb:
+		n, rest, err := readUint(data, 8)
+		return math.Float64frombits(n), rest, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, rest, err := readUint(data, 1<<(c-0xcc))
+		if err == nil && n > math.MaxInt64 {
+			return float64(n), rest, nil
+		}
+		return int64(n), rest, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, rest, err := readUint(data, size)
+		shift := uint(64 - 8*size) // 符号扩展
+		return int64(n<<shift) >> shift, rest, err
+	case 0xd9, 0xda, 0xdb:
+		n, rest, err := readUint(data, 1<<(c-0xd9))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeStr(rest, int(n))
+	case 0xdc, 0xdd:
+		n, rest, err := readUint(data, 2<<(c-0xdc))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeArray(rest, int(n))
+	case 0xde, 0xdf:
+		n, rest, err := readUint(data, 2<<(c-0xde))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decodeMap(rest, int(n))
+	}
+	return nil, nil, fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+}
+
+// 读取 size 字节的大端无符号整数
+func readUint(data []byte, size int) (uint64, []byte, error) {
+	if len(data) < size {
+		return 0, nil, errShortMsgpack
+	}
+	var n uint64
+	for _, b := range data[:size] {
+		n = n<<8 | uint64(b)
+	}
+	return n, data[size:], nil
+}
+
+func decodeStr(data []byte, n int) (interface{}, []byte, error) {
+	if n < 0 || len(data) < n {
+		return nil, nil, errShortMsgpack
+	}
+	return string(data[:n]), data[n:], nil
+}
+
+func decodeArray(data []byte, n int) (interface{}, []byte, error) {
+	arr := make([]interface{}, 0, min(n, len(data)))
+	for i := 0; i < n; i++ {
+		v, rest, err := decodeMsgpack(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		arr = append(arr, v)
+		data = rest
+	}
+	return arr, data, nil
+}
+
+// 解码映射，非字符串键转为其文本形式以便输出为 JSON
+func decodeMap(data []byte, n int) (interface{}, []byte, error) {
+	m := make(map[string]interface{}, min(n, len(data)))
+	for i := 0; i < n; i++ {
+		k, rest, err := decodeMsgpack(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, rest, err := decodeMsgpack(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		m[key] = v
+		data = rest
+	}
+	return m, data, nil
+}
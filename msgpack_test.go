@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// 通过真实 WebSocket 连接服务端，握手声明线路编码
+func dialWS(t *testing.T, url, name, encoding string) *websocket.Conn {
+	t.Helper()
+	ws, err := websocket.Dial(url, "", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	if err := websocket.JSON.Send(ws, map[string]string{"username": name, "encoding": encoding}); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+// 读取下一条聊天消息，跳过事件帧；MessagePack 帧先转换为 JSON
+func readChat(t *testing.T, ws *websocket.Conn, packed bool) Message {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			t.Fatal(err)
+		}
+		if packed {
+			var err error
+			if data, err = msgpackToJSON(data); err != nil {
+				t.Fatalf("frame is not MessagePack: %v", err)
+			}
+		} else if !json.Valid(data) {
+			t.Fatalf("frame is not JSON: %q", data)
+		}
+		var v map[string]json.RawMessage
+		_ = json.Unmarshal(data, &v)
+		if _, isEvent := v["type"]; isEvent {
+			continue
+		}
+		var msg Message
+		_ = json.Unmarshal(data, &msg)
+		return msg
+	}
+}
+
+func TestExchangeJSONAndMsgpack(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	srv := httptest.NewServer(websocket.Handler(wsHandler))
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	alice := dialWS(t, url, "alice", encodingMsgpack)
+	bob := dialWS(t, url, "bob", encodingJSON)
+	waitOnline(t, "alice", "bob")
+
+	packed, err := jsonToMsgpack([]byte(`{"to":"room","content":"packed hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := websocket.Message.Send(alice, packed); err != nil {
+		t.Fatal(err)
+	}
+	if got := readChat(t, bob, false); got.From != "alice" || got.Content != "packed hello" {
+		t.Fatalf("bob got %+v", got)
+	}
+
+	if err := websocket.JSON.Send(bob, map[string]string{"to": "room", "content": "json reply"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readChat(t, alice, true); got.Content != "packed hello" {
+		t.Fatalf("alice echo %+v", got)
+	}
+	if got := readChat(t, alice, true); got.From != "bob" || got.Content != "json reply" {
+		t.Fatalf("alice got %+v", got)
+	}
+}
+
+// 等待用户完成注册
+func waitOnline(t *testing.T, names ...string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for _, name := range names {
+		for len(userConns(name)) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s did not come online", name)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// 解析历史消息排序参数：order=asc|desc，sort=id|time，默认按 ID 升序
+func parseOrder(r *http.Request) (desc, byTime, ok bool) {
+	switch r.URL.Query().Get("order") {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return false, false, false
+	}
+	switch r.URL.Query().Get("sort") {
+	case "", "id":
+	case "time":
+		byTime = true
+	default:
+		return false, false, false
+	}
+	return desc, byTime, true
+}
+
+// 按指定方式排序消息，时间相同时以 ID 作为次序
+func sortMessages(msgs []Message, desc, byTime bool) {
+	sort.SliceStable(msgs, func(i, j int) bool {
+		a, b := msgs[i], msgs[j]
+		if desc {
+			a, b = b, a
+		}
+		if byTime && !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.ID < b.ID
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+var (
+	sessionOrders = make(map[string][]string) // 用户自定义的会话排序
+	orderMu       sync.Mutex
+)
+
+// 处理 reorder_sessions 帧：保存用户自定义的侧边栏顺序
+func handleReorderSessions(user *User, data []byte) {
+	var req struct {
+		Order []string `json:"order"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的排序请求")
+		return
+	}
+	orderMu.Lock()
+	if len(req.Order) == 0 {
+		delete(sessionOrders, user.Username)
+	} else {
+		sessionOrders[user.Username] = append([]string(nil), req.Order...)
+	}
+	orderMu.Unlock()
+	user.enqueue(Event{Type: "sessions_reordered", Data: req.Order})
+}
+
+// 按用户自定义顺序排列会话：指定的会话在前，其余按最后活跃时间倒序；未自定义时全部按最后活跃时间倒序
+func applyUserOrder(username string, list []SessionDetail) []SessionDetail {
+	orderMu.Lock()
+	order := sessionOrders[username]
+	orderMu.Unlock()
+
+	rank := make(map[string]int, len(order))
+	for i, id := range order {
+		if _, dup := rank[id]; !dup {
+			rank[id] = i
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		ri, iok := rank[list[i].ID]
+		rj, jok := rank[list[j].ID]
+		switch {
+		case iok && jok:
+			return ri < rj
+		case iok != jok:
+			return iok
+		default:
+			return list[i].LastTime.After(list[j].LastTime)
+		}
+	})
+	return list
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func detailsAt(now time.Time, ages map[string]int) []SessionDetail {
+	var list []SessionDetail
+	for _, id := range []string{"a", "b", "c", "d"} {
+		list = append(list, SessionDetail{Session: Session{ID: id, LastTime: now.Add(-time.Duration(ages[id]) * time.Minute)}})
+	}
+	return list
+}
+
+func detailIDs(list []SessionDetail) []string {
+	var res []string
+	for _, d := range list {
+		res = append(res, d.ID)
+	}
+	return res
+}
+
+func TestApplyUserOrder(t *testing.T) {
+	now := time.Now()
+	ages := map[string]int{"a": 30, "b": 10, "c": 20, "d": 5}
+	t.Cleanup(func() {
+		orderMu.Lock()
+		delete(sessionOrders, "alice")
+		orderMu.Unlock()
+	})
+
+	// 未自定义顺序时按最后活跃时间倒序
+	if got := detailIDs(applyUserOrder("alice", detailsAt(now, ages))); !equalStrings(got, []string{"d", "b", "c", "a"}) {
+		t.Fatalf("no custom order: got %v", got)
+	}
+
+	// 自定义的会话在前，其余按最后活跃时间倒序
+	orderMu.Lock()
+	sessionOrders["alice"] = []string{"c", "a"}
+	orderMu.Unlock()
+	if got := detailIDs(applyUserOrder("alice", detailsAt(now, ages))); !equalStrings(got, []string{"c", "a", "d", "b"}) {
+		t.Fatalf("custom order: got %v", got)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package main
+
+import "encoding/json"
+
+// 置顶数量超限时的处理策略
+const (
+	PinPolicyReject = "reject" // 拒绝新的置顶
+	PinPolicyEvict  = "evict"  // 移除最早的置顶
+)
+
+var (
+	maxPins   = envInt("MAX_PINS", 10)
+	pinPolicy = envString("PIN_POLICY", PinPolicyEvict)
+)
+
+// 置顶事件内容
+type PinEvent struct {
+	SessionID string `json:"session_id"`
+	MessageID int64  `json:"message_id"`
+	By        string `json:"by"`
+}
+
+// 处理 pin / unpin 帧
+func handlePin(user *User, data []byte, pin bool) {
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的置顶请求")
+		return
+	}
+	msg, ok := findMessage(req.ID)
+	if !ok || !visibleTo(msg, user.Username) {
+		sendError(user, "not_found", "消息不存在")
+		return
+	}
+	if !ensureMember(msg.To, user.Username) {
+		sendError(user, "not_member", "你不是该会话成员")
+		return
+	}
+	// 置顶对全体成员可见，悄悄话即使对操作者可见也不能置顶
+	if pin && len(msg.Whisper) > 0 {
+		sendError(user, "forbidden", "悄悄话不能置顶")
+		return
+	}
+
+	if !pin {
+		if unpinMessage(msg.To, msg.ID) {
+			broadcastEvent(msg.To, Event{Type: "unpinned", Data: PinEvent{SessionID: msg.To, MessageID: msg.ID, By: user.Username}})
+			postSystemMessage(msg.To, "unpinned", []string{user.Username}, user.Username)
+		}
+		return
+	}
+
+	evicted, added, err := pinMessage(msg.To, msg.ID)
+	if err != "" {
+		sendError(user, err, "置顶数量已达上限")
+		return
+	}
+	if !added {
+		return
+	}
+	if evicted != 0 {
+		broadcastEvent(msg.To, Event{Type: "unpinned", Data: PinEvent{SessionID: msg.To, MessageID: evicted, By: user.Username}})
+	}
+	broadcastEvent(msg.To, Event{Type: "pinned", Data: PinEvent{SessionID: msg.To, MessageID: msg.ID, By: user.Username}})
+	postSystemMessage(msg.To, "pinned", []string{user.Username}, user.Username)
+}
+
+// 置顶消息，返回被挤出的置顶 ID 及是否新增；超限且策略为拒绝时返回错误码
+func pinMessage(sessionID string, id int64) (evicted int64, added bool, errCode string) {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	s, ok := sessions[sessionID]
+	if !ok {
+		return 0, false, "unknown_session"
+	}
+	for _, p := range s.Pinned {
+		if p == id {
+			return 0, false, ""
+		}
+	}
+
+	// 写时复制，避免影响已取出的会话快照
+	pinned := append([]int64(nil), s.Pinned...)
+	if maxPins > 0 && len(pinned) >= maxPins {
+		if pinPolicy == PinPolicyReject {
+			return 0, false, "pin_limit"
+		}
+		evicted = pinned[0]
+		pinned = pinned[1:]
+	}
+	s.Pinned = append(pinned, id)
+	return evicted, true, ""
+}
+
+// 取消置顶，消息未置顶时返回 false
+func unpinMessage(sessionID string, id int64) bool {
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	s, ok := sessions[sessionID]
+	if !ok {
+		return false
+	}
+	pinned := make([]int64, 0, len(s.Pinned))
+	for _, p := range s.Pinned {
+		if p != id {
+			pinned = append(pinned, p)
+		}
+	}
+	if len(pinned) == len(s.Pinned) {
+		return false
+	}
+	s.Pinned = pinned
+	return true
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPinRefusesWhispers(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob", "carol")
+	w := seedMessage(Message{From: "alice", To: "room", Content: "psst", Whisper: []string{"bob"}})
+	bob := connect(t, "bob")
+	carol := connect(t, "carol")
+
+	pinErr := func(c *testClient) string {
+		c.send(map[string]interface{}{"type": "pin", "id": w.ID})
+		var info ErrorInfo
+		_ = json.Unmarshal(c.event("error"), &info)
+		return info.Code
+	}
+	if code := pinErr(carol); code != "not_found" {
+		t.Fatalf("non-recipient got %q", code)
+	}
+	if code := pinErr(bob); code != "forbidden" {
+		t.Fatalf("recipient got %q", code)
+	}
+	carol.noEvent("pinned", 50*time.Millisecond)
+	if s, _ := getSession("room"); len(s.Pinned) != 0 {
+		t.Fatalf("pinned %v", s.Pinned)
+	}
+}
+
+// 设置置顶上限与策略，测试结束后恢复
+func withPinLimit(t *testing.T, n int, policy string) {
+	t.Helper()
+	oldMax, oldPolicy := maxPins, pinPolicy
+	maxPins, pinPolicy = n, policy
+	t.Cleanup(func() { maxPins, pinPolicy = oldMax, oldPolicy })
+}
+
+func pinAll(t *testing.T, c *testClient, ids ...int64) {
+	t.Helper()
+	for _, id := range ids {
+		c.send(map[string]interface{}{"type": "pin", "id": id})
+		var ev PinEvent
+		_ = json.Unmarshal(c.event("pinned"), &ev)
+		if ev.MessageID != id {
+			t.Fatalf("pinned %d, want %d", ev.MessageID, id)
+		}
+	}
+}
+
+func TestPinLimitRejectPolicy(t *testing.T) {
+	resetState(t)
+	withPinLimit(t, 2, PinPolicyReject)
+	newTestSession("room", true, "alice")
+	a := seedMessage(Message{From: "alice", To: "room", Content: "a"})
+	b := seedMessage(Message{From: "alice", To: "room", Content: "b"})
+	c := seedMessage(Message{From: "alice", To: "room", Content: "c"})
+	alice := connect(t, "alice")
+	pinAll(t, alice, a.ID, b.ID)
+
+	alice.send(map[string]interface{}{"type": "pin", "id": c.ID})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "pin_limit" {
+		t.Fatalf("got %+v", info)
+	}
+	if s, _ := getSession("room"); len(s.Pinned) != 2 || s.Pinned[0] != a.ID || s.Pinned[1] != b.ID {
+		t.Fatalf("pinned %v", s.Pinned)
+	}
+}
+
+func TestPinLimitEvictPolicy(t *testing.T) {
+	resetState(t)
+	withPinLimit(t, 2, PinPolicyEvict)
+	newTestSession("room", true, "alice")
+	a := seedMessage(Message{From: "alice", To: "room", Content: "a"})
+	b := seedMessage(Message{From: "alice", To: "room", Content: "b"})
+	c := seedMessage(Message{From: "alice", To: "room", Content: "c"})
+	alice := connect(t, "alice")
+	pinAll(t, alice, a.ID, b.ID)
+
+	// 最早的置顶被挤出，先收到其 unpinned 事件
+	alice.send(map[string]interface{}{"type": "pin", "id": c.ID})
+	var ev PinEvent
+	_ = json.Unmarshal(alice.event("unpinned"), &ev)
+	if ev.MessageID != a.ID {
+		t.Fatalf("evicted %d, want %d", ev.MessageID, a.ID)
+	}
+	_ = json.Unmarshal(alice.event("pinned"), &ev)
+	if ev.MessageID != c.ID {
+		t.Fatalf("pinned %d, want %d", ev.MessageID, c.ID)
+	}
+	if s, _ := getSession("room"); len(s.Pinned) != 2 || s.Pinned[0] != b.ID || s.Pinned[1] != c.ID {
+		t.Fatalf("pinned %v", s.Pinned)
+	}
+}
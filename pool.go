@@ -0,0 +1,62 @@
+package main
+
+import (
+	"hash/fnv"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// 广播工作协程数量，0 表示在发送者协程内直接分发
+var broadcastWorkers = envInt("BROADCAST_WORKERS", 4)
+
+// 每个工作协程的任务队列长度
+const broadcastQueueSize = 1024
+
+var (
+	broadcastQueues []chan func()
+	broadcastOnce   sync.Once
+)
+
+// 启动广播工作池，每个协程拥有独立队列
+func startBroadcastPool() {
+	for i := 0; i < broadcastWorkers; i++ {
+		q := make(chan func(), broadcastQueueSize)
+		broadcastQueues = append(broadcastQueues, q)
+		go func() {
+			for task := range q {
+				safeRun(task)
+			}
+		}()
+	}
+}
+
+// 后台协程的 panic 只记录日志，不影响服务进程，需在协程入口 defer 调用
+func recoverLog(what string) {
+	if err := recover(); err != nil {
+		log.Printf("%s异常已恢复: err=%v\n%s", what, err, debug.Stack())
+	}
+}
+
+// 执行单个投递任务，panic 时丢弃该任务，工作协程继续处理后续任务
+func safeRun(task func()) {
+	defer recoverLog("消息投递")
+	task()
+}
+
+// 按会话 ID 分片交给工作池执行，同一会话的消息与事件按提交顺序投递
+func runInSession(sessionID string, task func()) {
+	if broadcastWorkers <= 0 {
+		task()
+		return
+	}
+	broadcastOnce.Do(startBroadcastPool)
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(sessionID))
+	broadcastQueues[h.Sum32()%uint32(len(broadcastQueues))] <- task
+}
+
+// 广播消息：经会话分片投递，保证同一会话内消息顺序不变
+func broadcast(msg Message) {
+	runInSession(msg.To, func() { deliver(msg) })
+}
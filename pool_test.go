@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// 注册 n 个在线接收者，后台持续清空其发送队列
+func benchmarkRecipients(b *testing.B, n int) {
+	resetState(b)
+	newTestSession("big", true)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("u%d", i)
+		addMember("big", name)
+		u := newUser(name, "", nil)
+		if !registerUser(u) {
+			b.Fatal("register failed")
+		}
+		go func() {
+			for {
+				select {
+				case <-u.send:
+				case <-u.urgent:
+				case <-u.done:
+					return
+				}
+			}
+		}()
+		b.Cleanup(func() { close(u.done) })
+	}
+}
+
+// 对比发送者协程内同步分发与交给工作池时发送方的耗时
+func BenchmarkBroadcast(b *testing.B) {
+	for _, workers := range []int{0, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkRecipients(b, 1000)
+			broadcastWorkers = workers
+			msg := Message{ID: 1, From: "sender", To: "big", Content: "hello"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				broadcast(msg)
+			}
+		})
+	}
+}
+
+// 让投递在遍历到该用户时 panic：在线表中混入空连接
+func injectBrokenConn(t *testing.T, sessionID string) {
+	addMember(sessionID, "broken")
+	userMu.Lock()
+	users["broken"] = map[*User]bool{nil: true}
+	userMu.Unlock()
+	t.Cleanup(func() {
+		userMu.Lock()
+		delete(users, "broken")
+		userMu.Unlock()
+	})
+}
+
+// 同步分发路径上 panic 被上层恢复后，userMu 不能保持锁定
+func TestDeliverPanicReleasesUserMu(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	injectBrokenConn(t, "room")
+	func() {
+		defer func() { _ = recover() }()
+		deliver(Message{ID: 1, From: "alice", To: "room", Content: "x"})
+		t.Fatal("expected deliver to panic")
+	}()
+	if !userMu.TryLock() {
+		t.Fatal("userMu left locked after panic")
+	}
+	userMu.Unlock()
+}
+
+// 工作协程恢复投递中的 panic，同一协程上的后续消息照常投递
+func TestBroadcastWorkerSurvivesPanic(t *testing.T) {
+	resetState(t)
+	broadcastWorkers, broadcastQueues, broadcastOnce = 1, nil, sync.Once{}
+	newTestSession("bad", true, "alice")
+	newTestSession("room", true, "alice", "bob")
+	injectBrokenConn(t, "bad")
+	bob := connect(t, "bob")
+
+	broadcast(Message{ID: 1, From: "alice", To: "bad", Content: "panics"})
+	broadcast(Message{ID: 2, From: "alice", To: "room", Content: "after"})
+	if got := bob.message(); got.ID != 2 {
+		t.Fatalf("bob got %+v", got)
+	}
+}
+
+// 开启工作池时，会话事件与消息同分片排队，不会先于其引用的消息到达
+func TestSessionEventFollowsMessage(t *testing.T) {
+	resetState(t)
+	broadcastWorkers, broadcastQueues, broadcastOnce = 4, nil, sync.Once{}
+	newTestSession("room", true, "alice", "bob")
+	bob := connect(t, "bob")
+
+	for i := int64(1); i <= 20; i++ {
+		broadcast(Message{ID: i, From: "alice", To: "room", Content: "x"})
+		broadcastEvent("room", Event{Type: "pinned", Data: PinEvent{SessionID: "room", MessageID: i}})
+		if first := bob.next(); first["type"] != nil {
+			t.Fatalf("round %d: event arrived before its message: %s", i, first["type"])
+		}
+		var ev PinEvent
+		_ = json.Unmarshal(bob.event("pinned"), &ev)
+		if ev.MessageID != i {
+			t.Fatalf("round %d: got pin for %d", i, ev.MessageID)
+		}
+	}
+}
+
+// 连接协程内的 panic 只关闭该连接，服务与其他客户端照常工作
+func TestConnectionPanicClosesOnlyThatConnection(t *testing.T) {
+	resetState(t)
+	newTestSession("bad", true, "alice")
+	newTestSession("room", true, "bob", "carol")
+	injectBrokenConn(t, "bad")
+	alice := connect(t, "alice")
+	bob := connect(t, "bob")
+	carol := connect(t, "carol")
+
+	alice.send(map[string]string{"to": "bad", "content": "panics"})
+	if code, _ := closedWith(alice); code != CloseInternalError {
+		t.Fatalf("panicking connection closed with %d", code)
+	}
+	if !userMu.TryLock() {
+		t.Fatal("userMu left locked after panic")
+	}
+	userMu.Unlock()
+
+	bob.send(map[string]string{"to": "room", "content": "still up"})
+	if got := carol.message(); got.Content != "still up" {
+		t.Fatalf("carol got %+v", got)
+	}
+	connect(t, "dave") // 仍能接受新连接
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 通知模式
+const (
+	NotifyAll      = "all"      // 所有消息
+	NotifyMentions = "mentions" // 仅被 @ 时
+	NotifyMute     = "mute"     // 全部静音
+)
+
+// 用户通知偏好
+type NotifyPrefs struct {
+	Mode string `json:"mode"`
+}
+
+// 通知事件内容
+type Notification struct {
+	SessionID string `json:"session_id"`
+	MessageID int64  `json:"message_id"`
+	From      string `json:"from"`
+	Preview   string `json:"preview"`
+}
+
+var (
+	prefs  = make(map[string]NotifyPrefs) // 用户名 -> 通知偏好
+	prefMu sync.Mutex
+)
+
+// 通知偏好文件：整体写入全部偏好，偏好修改不频繁。启用消息存储时默认为 MESSAGES_FILE 加 .prefs 后缀，
+// 受 prefMu 保护
+var prefsFile string
+
+// 从文件加载通知偏好，文件不存在时保持为空
+func loadPrefs(path string) {
+	prefMu.Lock()
+	defer prefMu.Unlock()
+	prefsFile = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("读取通知偏好失败: %v", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		log.Printf("读取通知偏好失败: %v", err)
+	}
+}
+
+// 先写临时文件再替换，写入中途崩溃不会损坏原文件；调用方需持有 prefMu
+func savePrefsLocked() {
+	if prefsFile == "" {
+		return
+	}
+	data, _ := json.Marshal(prefs)
+	tmp := prefsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		log.Printf("保存通知偏好失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, prefsFile); err != nil {
+		log.Printf("保存通知偏好失败: %v", err)
+	}
+}
+
+// 获取用户通知偏好，未设置时默认接收所有通知
+func getPrefs(username string) NotifyPrefs {
+	prefMu.Lock()
+	defer prefMu.Unlock()
+	p, ok := prefs[username]
+	if !ok {
+		return NotifyPrefs{Mode: NotifyAll}
+	}
+	return p
+}
+
+// 处理 set_prefs 帧
+func handleSetPrefs(user *User, data []byte) {
+	var req NotifyPrefs
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的偏好设置")
+		return
+	}
+	switch req.Mode {
+	case NotifyAll, NotifyMentions, NotifyMute:
+	default:
+		sendError(user, "bad_prefs", "不支持的通知模式")
+		return
+	}
+
+	prefMu.Lock()
+	prefs[user.Username] = req
+	savePrefsLocked()
+	prefMu.Unlock()
+	user.enqueue(Event{Type: "prefs", Data: req})
+}
+
+// 判断消息是否 @ 了指定用户：@ 前后都须是名字边界，@alice 不算提及 al，邮箱地址也不算
+func mentions(content, username string) bool {
+	tag := "@" + username
+	for i := strings.Index(content, tag); i >= 0; {
+		before, _ := utf8.DecodeLastRuneInString(content[:i])
+		rest := content[i+len(tag):]
+		if (i == 0 || !nameRune(before)) && !continuesName(rest) {
+			return true
+		}
+		next := strings.Index(content[i+1:], tag)
+		if next < 0 {
+			break
+		}
+		i += 1 + next
+	}
+	return false
+}
+
+// 用户名中可出现的字符
+func nameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+// 判断 @name 之后的文本是否仍属于更长的用户名；句末的 . 或 - 后面没有名字字符时不算
+func continuesName(rest string) bool {
+	rest = strings.TrimLeft(rest, ".-")
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest != "" && nameRune(r)
+}
+
+// 根据接收者的通知偏好决定是否下发通知，会话通知暂停期间一律不通知
+func shouldNotify(username string, msg Message) bool {
+	if snoozed(username, msg.To, time.Now()) {
+		return false
+	}
+	switch getPrefs(username).Mode {
+	case NotifyMute:
+		return false
+	case NotifyMentions:
+		return mentions(msg.Content, username)
+	default:
+		return true
+	}
+}
+
+// 生成通知事件
+func notification(msg Message) Event {
+	return Event{Type: "notify", Data: notificationFor(msg)}
+}
+
+// 生成通知内容，站内通知与离线推送共用
+func notificationFor(msg Message) Notification {
+	return Notification{
+		SessionID: msg.To,
+		MessageID: msg.ID,
+		From:      msg.From,
+		Preview:   truncate(msg.Content, snippetLength),
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMentionsMatchesWholeName(t *testing.T) {
+	cases := []struct {
+		content, name string
+		want          bool
+	}{
+		{"@al hi", "al", true},
+		{"hi @al", "al", true},
+		{"hi @al.", "al", true},
+		{"@al, look", "al", true},
+		{"（@al）", "al", true},
+		{"@alice hi", "al", false},
+		{"@al.b hi", "al", false},
+		{"@al_2", "al", false},
+		{"mail bob@al", "al", false},
+		{"@alice and @al", "al", true},
+		{"@alice", "alice", true},
+	}
+	for _, c := range cases {
+		if got := mentions(c.content, c.name); got != c.want {
+			t.Errorf("mentions(%q, %q) = %v, want %v", c.content, c.name, got, c.want)
+		}
+	}
+}
+
+// 偏好写入文件，重新加载后仍然生效
+func TestPrefsSurviveReload(t *testing.T) {
+	resetState(t)
+	loadPrefs(filepath.Join(t.TempDir(), "messages.jsonl.prefs"))
+	alice := connect(t, "alice")
+	alice.send(map[string]string{"type": "set_prefs", "mode": NotifyMentions})
+	alice.event("prefs")
+
+	prefMu.Lock()
+	path := prefsFile
+	prefs = make(map[string]NotifyPrefs)
+	prefMu.Unlock()
+	loadPrefs(path)
+	if got := getPrefs("alice").Mode; got != NotifyMentions {
+		t.Fatalf("after reload got %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// 在线状态
+const (
+	PresenceOnline = "online"
+	PresenceAway   = "away"
+	PresenceDND    = "dnd" // 勿扰，期间不下发通知
+)
+
+// 无活动多久后自动切换为离开，0 表示关闭
+var awayAfter = envDuration("AWAY_AFTER", 5*time.Minute)
+
+// 在线状态事件内容
+type PresenceEvent struct {
+	User  string `json:"user"`
+	State string `json:"state"`
+}
+
+// 读取当前在线状态
+func (u *User) presence() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Presence
+}
+
+// 设置在线状态，状态发生变化时返回 true
+func (u *User) setPresence(state string, auto bool) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.autoAway = auto
+	if u.Presence == state {
+		return false
+	}
+	u.Presence = state
+	return true
+}
+
+// 记录一次活动，自动离开的用户恢复在线
+func (u *User) touch() {
+	u.mu.Lock()
+	u.LastActive = time.Now()
+	back := u.autoAway
+	u.mu.Unlock()
+	if back && u.setPresence(PresenceOnline, false) {
+		broadcastPresence(u)
+	}
+}
+
+// 向其他在线用户广播状态变化
+func broadcastPresence(u *User) {
+	ev := Event{Type: "presence", Data: PresenceEvent{User: u.Username, State: u.presence()}}
+	userMu.Lock()
+	defer userMu.Unlock()
+	for _, conns := range users {
+		for other := range conns {
+			if other != u {
+				other.enqueue(ev)
+			}
+		}
+	}
+}
+
+// 处理 set_presence 帧
+func handleSetPresence(user *User, data []byte) {
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的状态设置")
+		return
+	}
+	switch req.State {
+	case PresenceOnline, PresenceAway, PresenceDND:
+	default:
+		sendError(user, "bad_presence", "不支持的在线状态")
+		return
+	}
+	if user.setPresence(req.State, false) {
+		broadcastPresence(user)
+	}
+}
+
+// 会话在线成员事件内容
+type OnlineEvent struct {
+	SessionID string   `json:"session_id"`
+	Users     []string `json:"users"`
+}
+
+// 处理 get_online 帧：返回会话成员中当前在线的用户（按用户名排序）
+func handleGetOnline(user *User, data []byte) {
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的查询请求")
+		return
+	}
+	if !ensureMember(req.SessionID, user.Username) {
+		sendError(user, "not_member", "你不是该会话成员")
+		return
+	}
+
+	recipients := sessionMembers(req.SessionID)
+	online := make([]string, 0)
+	userMu.Lock()
+	for name := range users {
+		if recipients[name] {
+			online = append(online, name)
+		}
+	}
+	userMu.Unlock()
+	sort.Strings(online)
+	user.enqueue(Event{Type: "online", Data: OnlineEvent{SessionID: req.SessionID, Users: online}})
+}
+
+// 空闲检测循环：长时间无活动的在线用户自动切换为离开
+func (u *User) idleLoop() {
+	if awayAfter <= 0 {
+		return
+	}
+	interval := awayAfter / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.mu.Lock()
+			idle := u.Presence == PresenceOnline && time.Since(u.LastActive) >= awayAfter
+			u.mu.Unlock()
+			if idle && u.setPresence(PresenceAway, true) {
+				broadcastPresence(u)
+			}
+		case <-u.done:
+			return
+		}
+	}
+}
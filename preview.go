@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	previewHosts    = envString("PREVIEW_HOSTS", "") // 允许抓取预览的域名，逗号分隔，为空时关闭链接预览
+	previewMaxBytes = envInt("PREVIEW_MAX_BYTES", 256<<10)
+	previewTimeout  = envDuration("PREVIEW_TIMEOUT", 3*time.Second)
+	previewCacheTTL = envDuration("PREVIEW_CACHE_TTL", time.Hour)
+)
+
+// 链接预览
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// 预览就绪事件内容
+type PreviewEvent struct {
+	ID        int64        `json:"id"`
+	SessionID string       `json:"session_id"`
+	Preview   *LinkPreview `json:"preview"`
+}
+
+// 预览缓存，按规范化后的地址保存，条目数受 PREVIEW_CACHE_SIZE 限制
+var previewCache = newTTLCache[*LinkPreview](envInt("PREVIEW_CACHE_SIZE", 1000))
+
+// 抓取预览的客户端，重定向同样需要通过域名校验
+var previewClient = &http.Client{
+	Timeout: previewTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if !previewHostAllowed(req.URL.Hostname()) || len(via) >= 3 {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	},
+}
+
+// 判断域名是否在允许列表中
+func previewHostAllowed(host string) bool {
+	for _, h := range strings.Split(previewHosts, ",") {
+		if h = strings.TrimSpace(h); h != "" && strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// 取内容中第一个允许抓取预览的链接
+func firstPreviewURL(content string) string {
+	for _, word := range strings.Fields(content) {
+		if !strings.HasPrefix(word, "http://") && !strings.HasPrefix(word, "https://") {
+			continue
+		}
+		if u, err := url.Parse(word); err == nil && previewHostAllowed(u.Hostname()) {
+			return word
+		}
+	}
+	return ""
+}
+
+// 异步为消息生成链接预览，就绪后写回消息并推送 preview 事件；悄悄话不生成预览
+func schedulePreview(msg Message) {
+	if previewHosts == "" || len(msg.Whisper) > 0 {
+		return
+	}
+	rawURL := firstPreviewURL(msg.Content)
+	if rawURL == "" {
+		return
+	}
+	go func() {
+		defer recoverLog("链接预览")
+		p := cachedPreview(rawURL)
+		if p == nil {
+			return
+		}
+		updated, code := updateMessage(msg.ID, func(m *Message) string {
+			if maintenance.Load() {
+				return "maintenance"
+			}
+			m.Preview = p
+			return ""
+		})
+		if code != "" {
+			return // 抓取期间消息已被删除，或已进入维护模式
+		}
+		broadcastEvent(updated.To, Event{Type: "preview", Data: PreviewEvent{ID: updated.ID, SessionID: updated.To, Preview: p}})
+	}()
+}
+
+// 优先使用缓存，过期或未缓存时重新抓取；抓取失败也缓存为 nil，避免反复请求同一地址
+func cachedPreview(rawURL string) *LinkPreview {
+	key := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		key = cacheKey(u)
+	}
+	if p, ok := previewCache.get(key, time.Now()); ok {
+		return p
+	}
+	p, err := fetchPreview(rawURL)
+	if err != nil {
+		p = nil
+	}
+	previewCache.set(key, p, time.Now().Add(previewCacheTTL))
+	return p
+}
+
+// 抓取页面并解析 Open Graph 标签，缺少时退回 <title> 与 description
+func fetchPreview(rawURL string) (*LinkPreview, error) {
+	resp, err := previewClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, errors.New("not html")
+	}
+
+	p := &LinkPreview{URL: rawURL}
+	var title, desc string
+	z := html.NewTokenizer(io.LimitReader(resp.Body, int64(previewMaxBytes)))
+	inTitle := false
+	for done := false; !done; {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch {
+		case tt == html.EndTagToken && tok.Data == "head":
+			done = true // 只解析 <head>
+		case tt == html.TextToken && inTitle && title == "":
+			title = strings.TrimSpace(tok.Data)
+		case (tt == html.StartTagToken || tt == html.SelfClosingTagToken) && tok.Data == "meta":
+			var key, content string
+			for _, a := range tok.Attr {
+				switch a.Key {
+				case "property", "name":
+					key = strings.ToLower(a.Val)
+				case "content":
+					content = strings.TrimSpace(a.Val)
+				}
+			}
+			switch key {
+			case "og:title":
+				p.Title = content
+			case "og:description":
+				p.Description = content
+			case "og:image":
+				p.Image = content
+			case "description":
+				desc = content
+			}
+		}
+		inTitle = tt == html.StartTagToken && tok.Data == "title"
+	}
+
+	if p.Title == "" {
+		p.Title = title
+	}
+	if p.Description == "" {
+		p.Description = desc
+	}
+	if p.Title == "" && p.Description == "" && p.Image == "" {
+		return nil, errors.New("no preview data")
+	}
+	p.Title = truncate(p.Title, 200)
+	p.Description = truncate(p.Description, 300)
+	return p, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPreviewCacheIsBoundedAndShared(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>Hello</title></head></html>`))
+	}))
+	defer srv.Close()
+	hosts, cache := previewHosts, previewCache
+	previewHosts, previewCache = "127.0.0.1", newTTLCache[*LinkPreview](2)
+	t.Cleanup(func() { previewHosts, previewCache = hosts, cache })
+
+	if p := cachedPreview(srv.URL + "/?a=1&b=2"); p == nil || p.Title != "Hello" {
+		t.Fatalf("preview %+v", p)
+	}
+	cachedPreview(srv.URL + "/?b=2&a=1#top")
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("upstream hit %d times, want 1", n)
+	}
+	for _, q := range []string{"x", "y", "z"} {
+		cachedPreview(srv.URL + "/?q=" + q)
+	}
+	if n := previewCache.len(); n != 2 {
+		t.Fatalf("cache holds %d entries, want 2", n)
+	}
+}
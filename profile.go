@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 离线用户的在线状态
+const PresenceOffline = "offline"
+
+var (
+	lastSeen   = make(map[string]time.Time) // 用户最后一次断开连接时的活跃时间
+	lastSeenMu sync.Mutex
+)
+
+// 记录断开连接的用户最后活跃时间
+func recordLastSeen(u *User) {
+	lastSeenMu.Lock()
+	lastSeen[u.Username] = u.lastActive()
+	lastSeenMu.Unlock()
+}
+
+// 公开的用户资料，不包含会话列表等私密信息
+type Profile struct {
+	Username     string    `json:"username"`
+	DisplayName  string    `json:"display_name"`
+	Avatar       string    `json:"avatar,omitempty"`
+	Presence     string    `json:"presence"`
+	LastSeen     time.Time `json:"last_seen"`
+	SessionCount int       `json:"session_count"`
+}
+
+// 汇总用户资料：在线用户取连接上的信息，离线用户取最后活跃时间，从未出现过的用户返回 false
+func userProfile(username string) (Profile, bool) {
+	sessionCount := len(userSessions(username))
+	if u := onlineUser(username); u != nil {
+		return Profile{
+			Username:     u.Username,
+			DisplayName:  u.DisplayName,
+			Avatar:       u.Avatar,
+			Presence:     u.presence(),
+			LastSeen:     u.lastActive(),
+			SessionCount: sessionCount,
+		}, true
+	}
+
+	lastSeenMu.Lock()
+	seen, ok := lastSeen[username]
+	lastSeenMu.Unlock()
+	if !ok {
+		return Profile{}, false
+	}
+	displayName, avatar := identityResolver.Resolve(username)
+	if !avatarsEnabled {
+		avatar = ""
+	}
+	return Profile{
+		Username:     username,
+		DisplayName:  displayName,
+		Avatar:       avatar,
+		Presence:     PresenceOffline,
+		LastSeen:     seen,
+		SessionCount: sessionCount,
+	}, true
+}
+
+// 查询用户公开资料：GET /api/profile?user=
+func profileHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("user")
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p, ok := userProfile(username)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
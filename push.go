@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// 离线推送接口：私聊或被 @ 的离线用户通过外部通道（Web Push、FCM 等）接收通知
+type PushNotifier interface {
+	Notify(username string, n Notification) error
+}
+
+// 默认推送器：不做任何事
+type noopNotifier struct{}
+
+func (noopNotifier) Notify(string, Notification) error { return nil }
+
+// 示例推送器：以 JSON POST 到配置的地址，模拟对接 FCM / Web Push 网关
+type webhookNotifier struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (n webhookNotifier) Notify(username string, note Notification) error {
+	body, err := json.Marshal(struct {
+		User string `json:"user"`
+		Notification
+	}{username, note})
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// 当前使用的推送器：配置 PUSH_ENDPOINT 时使用示例推送器，部署方可替换为自己的实现
+var pushNotifier = newPushNotifier(os.Getenv("PUSH_ENDPOINT"))
+
+func newPushNotifier(endpoint string) PushNotifier {
+	if endpoint == "" {
+		return noopNotifier{}
+	}
+	return webhookNotifier{endpoint: endpoint, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// 向离线接收者推送：仅限私聊或被 @ 的用户，且须符合其通知偏好
+func pushOffline(msg Message, offline []string) {
+	defer recoverLog("离线推送")
+	s, ok := getSession(msg.To)
+	if !ok {
+		return
+	}
+	for _, name := range offline {
+		if s.IsGroup && !mentions(msg.Content, name) {
+			continue
+		}
+		if !shouldNotify(name, msg) {
+			continue
+		}
+		note := notificationFor(localize(msg, defaultLang))
+		if err := pushNotifier.Notify(name, note); err != nil {
+			log.Printf("离线推送失败: user=%q id=%d err=%v", name, msg.ID, err)
+		}
+	}
+}
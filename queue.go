@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 每个连接发送队列的长度
+var sendQueueSize = envInt("SEND_QUEUE_SIZE", 256)
+
+// 全局丢弃消息计数
+var droppedTotal int64
+
+// 消息投递优先级
+const (
+	PriorityNormal = 0
+	PriorityHigh   = 1 // 提及与管理员公告，插队到普通消息之前
+)
+
+// 因队列已满而丢弃的消息区间
+type Gap struct {
+	FromID  int64 `json:"from_id"`
+	ToID    int64 `json:"to_id"`
+	Dropped int   `json:"dropped"`
+}
+
+// 入队待发送数据，队列已满时丢弃并记录缺口
+func (u *User) enqueue(v interface{}) bool {
+	select {
+	case u.send <- v:
+		return true
+	default:
+	}
+	u.recordDrop(v)
+	return false
+}
+
+// 以高优先级入队，写循环会先发送这些数据
+func (u *User) enqueueUrgent(v interface{}) bool {
+	select {
+	case u.urgent <- v:
+		return true
+	default:
+	}
+	u.recordDrop(v)
+	return false
+}
+
+// 消息对该接收者是否为高优先级：服务端标记的优先消息或提及了接收者
+func urgentFor(msg Message, username string) bool {
+	return msg.Priority >= PriorityHigh || mentions(msg.Content, username)
+}
+
+// 记录被丢弃的数据，消息类数据会扩展缺口区间
+func (u *User) recordDrop(v interface{}) {
+	atomic.AddInt64(&droppedTotal, 1)
+	u.gapMu.Lock()
+	defer u.gapMu.Unlock()
+	if u.gap == nil {
+		u.gap = &Gap{}
+	}
+	u.gap.Dropped++
+	if tm, ok := v.(timedMessage); ok {
+		v = tm.msg
+	}
+	if msg, ok := v.(Message); ok {
+		if u.gap.FromID == 0 || msg.ID < u.gap.FromID {
+			u.gap.FromID = msg.ID
+		}
+		if msg.ID > u.gap.ToID {
+			u.gap.ToID = msg.ID
+		}
+	}
+}
+
+// 取出并清空待通知的缺口
+func (u *User) takeGap() *Gap {
+	u.gapMu.Lock()
+	defer u.gapMu.Unlock()
+	g := u.gap
+	u.gap = nil
+	return g
+}
+
+// 连接写循环：串行发送队列数据，高优先级队列优先，全部追平后补发缺口通知
+func (u *User) writeLoop() {
+	for {
+		var v interface{}
+		select {
+		case v = <-u.urgent:
+		default:
+			select {
+			case v = <-u.urgent:
+			case v = <-u.send:
+			case <-u.done:
+				return
+			}
+		}
+		if tm, ok := v.(timedMessage); ok {
+			if u.WS.Send(tm.msg) == nil {
+				deliveryLatency.observe(time.Since(tm.receivedAt))
+			}
+		} else {
+			_ = u.WS.Send(v)
+		}
+		if len(u.urgent) > 0 || len(u.send) > 0 {
+			continue
+		}
+		if g := u.takeGap(); g != nil {
+			_ = u.WS.Send(Event{Type: "gap", Data: g})
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// 启动写循环，返回读取其输出的测试客户端
+func startWriter(t *testing.T, u *User, client *pipeTransport) *testClient {
+	go u.writeLoop()
+	c := &testClient{t: t, name: u.Username, tr: client, frames: make(chan []byte, 16)}
+	go func() {
+		for {
+			data, err := client.Receive()
+			if err != nil {
+				return
+			}
+			c.frames <- data
+		}
+	}()
+	return c
+}
+
+// 发送队列写满时丢弃的消息在追平后以 gap 通知告知缺失区间
+func TestDroppedMessagesProduceGap(t *testing.T) {
+	size := sendQueueSize
+	sendQueueSize = 2
+	t.Cleanup(func() { sendQueueSize = size })
+	server, client := newPipeTransport()
+	u := newUser("bob", "", server)
+	t.Cleanup(func() { close(u.done) })
+
+	// 写循环尚未启动，前两条入队，其余被丢弃
+	for id := int64(1); id <= 5; id++ {
+		u.deliverMessage(Message{ID: id, From: "alice", To: "room", Content: "x"})
+	}
+	c := startWriter(t, u, client)
+
+	var ids []int64
+	for {
+		raw, _ := json.Marshal(c.next())
+		var frame struct {
+			ID   int64  `json:"id"`
+			Type string `json:"type"`
+			Data Gap    `json:"data"`
+		}
+		_ = json.Unmarshal(raw, &frame)
+		if frame.Type == "" {
+			ids = append(ids, frame.ID)
+			continue
+		}
+		if frame.Type != "gap" {
+			t.Fatalf("unexpected event %s", raw)
+		}
+		if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+			t.Fatalf("delivered %v before the gap", ids)
+		}
+		if g := frame.Data; g.FromID != 3 || g.ToID != 5 || g.Dropped != 3 {
+			t.Fatalf("gap %+v, want 3..5 with 3 dropped", g)
+		}
+		return
+	}
+}
+
+// 积压时高优先级消息（服务端标记的优先消息、提及接收者的消息）先于已排队的普通消息写出
+func TestPriorityMessagesOvertakeQueued(t *testing.T) {
+	server, client := newPipeTransport()
+	u := newUser("bob", "", server)
+	t.Cleanup(func() { close(u.done) })
+
+	for id := int64(1); id <= 3; id++ {
+		u.deliverMessage(Message{ID: id, From: "alice", To: "room", Content: "chatter"})
+	}
+	u.deliverMessage(Message{ID: 4, From: "admin", To: "room", Content: "announcement", Priority: PriorityHigh})
+	u.deliverMessage(Message{ID: 5, From: "alice", To: "room", Content: "@bob look"})
+	c := startWriter(t, u, client)
+
+	var ids []int64
+	for len(ids) < 5 {
+		ids = append(ids, c.message().ID)
+	}
+	want := []int64{4, 5, 1, 2, 3}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("delivery order %v, want %v", ids, want)
+		}
+	}
+}
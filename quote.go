@@ -0,0 +1,73 @@
+package main
+
+import "fmt"
+
+// 引用片段最大字符数
+const snippetLength = 50
+
+// 引用原消息时内嵌的片段
+type QuotedSnippet struct {
+	ID      int64  `json:"id"`
+	From    string `json:"from"`
+	Content string `json:"content"`
+}
+
+// 单条消息最多同时回复的消息数
+var maxReplyTo = envInt("MAX_REPLY_TO", 10)
+
+// 按字符截断文本，超出部分以省略号代替
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
+
+// 生成引用片段，原消息不存在、不属于同一会话或对引用者不可见时显示为已删除
+func quoteSnippet(sessionID string, id int64, viewer string) *QuotedSnippet {
+	orig, ok := findMessage(id)
+	if !ok || orig.To != sessionID || !visibleTo(orig, viewer) {
+		return &QuotedSnippet{ID: id, Content: "[deleted]"}
+	}
+	return &QuotedSnippet{ID: id, From: orig.From, Content: truncate(orig.Content, snippetLength)}
+}
+
+// 填充回复片段：兼容旧的单条 ReplyTo，多条回复时要求每条原消息都存在于同一会话，
+// 校验失败返回错误码与提示
+func resolveQuotes(msg *Message) (string, string) {
+	msg.Quoted, msg.Quotes = nil, nil
+	if len(msg.ReplyToIDs) == 0 {
+		if msg.ReplyTo != 0 {
+			msg.Quoted = quoteSnippet(msg.To, msg.ReplyTo, msg.From)
+		}
+		return "", ""
+	}
+
+	// 旧字段并入列表首位，去重并保持顺序
+	ids := make([]int64, 0, len(msg.ReplyToIDs)+1)
+	seen := make(map[int64]bool)
+	for _, id := range append([]int64{msg.ReplyTo}, msg.ReplyToIDs...) {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if maxReplyTo > 0 && len(ids) > maxReplyTo {
+		return "too_many_replies", fmt.Sprintf("最多同时回复 %d 条消息", maxReplyTo)
+	}
+	for _, id := range ids {
+		orig, ok := findMessage(id)
+		if !ok || orig.To != msg.To || !visibleTo(orig, msg.From) {
+			return "unknown_reply", fmt.Sprintf("回复的消息 %d 不存在", id)
+		}
+	}
+
+	msg.ReplyToIDs = ids
+	msg.ReplyTo = ids[0]
+	for _, id := range ids {
+		msg.Quotes = append(msg.Quotes, quoteSnippet(msg.To, id, msg.From))
+	}
+	msg.Quoted = msg.Quotes[0]
+	return "", ""
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// 已读事件内容
+type ReadEvent struct {
+	SessionID string `json:"session_id"`
+	User      string `json:"user"`
+	UpTo      int64  `json:"up_to"`
+}
+
+// 判断消息是否被指定用户读过：ID 不超过其已读位置，或被显式记为读者
+func readBy(msg Message, username string, mark int64) bool {
+	return msg.ID <= mark || containsString(msg.ReadBy, username)
+}
+
+// 将会话中 ID 不大于 upTo 的消息标记为指定用户已读，返回截断后的位置及是否有变化
+func markRead(username, sessionID string, upTo int64) (int64, bool) {
+	// 不超过已分配的最大 ID，避免提前把未来的消息标为已读
+	msgMu.Lock()
+	if last := msgID - 1; upTo > last {
+		upTo = last
+	}
+	msgMu.Unlock()
+	prev, changed := advanceReadMark(username, sessionID, upTo)
+	if !changed {
+		return prev, false
+	}
+	touchReadRange(username, sessionID, prev, upTo)
+	saveReadMark(username, sessionID, upTo)
+	return upTo, true
+}
+
+// 处理 read 帧
+func handleRead(user *User, data []byte) {
+	var req struct {
+		SessionID string `json:"session_id"`
+		ID        int64  `json:"id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的已读回执")
+		return
+	}
+	if !ensureMember(req.SessionID, user.Username) {
+		sendError(user, "not_member", "你不是该会话成员")
+		return
+	}
+	readSession(user.Username, req.SessionID, req.ID)
+}
+
+// 标记会话已读，已读位置有推进时向成员广播已读事件
+func readSession(username, sessionID string, upTo int64) {
+	upTo, changed := markRead(username, sessionID, upTo)
+	if !changed {
+		return
+	}
+	broadcastEvent(sessionID, Event{Type: "read", Data: ReadEvent{SessionID: sessionID, User: username, UpTo: upTo}})
+}
+
+// 全部标为已读：POST /api/read-all?user=，返回各会话新标记的数量
+func readAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	username := r.URL.Query().Get("user")
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// 每个有未读的会话标记到最后一条未读消息
+	upTo := make(map[string]int64)
+	res := make(map[string]int)
+	for _, msg := range unreadMessages(username) {
+		upTo[msg.To] = msg.ID // 按 ID 升序，最后写入的即最大值
+		res[msg.To]++
+	}
+	for sid, id := range upTo {
+		readSession(username, sid, id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// 返回用户在其所有会话中的未读消息（按消息 ID 顺序）
+func unreadMessages(username string) []Message {
+	joined := userSessions(username)
+	marks := userReadMarks(username)
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	var res []Message
+	for _, msg := range messages {
+		if joined[msg.To] && msg.From != username && !msg.Deleted && visibleTo(msg, username) && !readBy(msg, username, marks[msg.To]) {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
+// 统计用户各个已加入会话的未读数，没有未读的会话计为 0
+func unreadCounts(username string) map[string]int {
+	counts := make(map[string]int)
+	for sid := range userSessions(username) {
+		counts[sid] = 0
+	}
+	for _, msg := range unreadMessages(username) {
+		counts[msg.To]++
+	}
+	return counts
+}
+
+// 一次返回用户所有会话的未读数：GET /api/unread?user=
+func unreadHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("user")
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(unreadCounts(username))
+}
+
+// 所有会话的未读总数，用作应用角标
+func badgeCount(username string) int {
+	return len(unreadMessages(username))
+}
+
+// 处理 get_badge 帧
+func handleGetBadge(user *User) {
+	user.enqueue(Event{Type: "badge", Data: badgeCount(user.Username)})
+}
+
+// 查询角标数：GET /api/badge?user=
+func badgeHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("user")
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"badge": badgeCount(username)})
+}
+
+// 推送所有未读消息，分批发完后发送 replay_done 事件
+func replayUnread(user *User) {
+	unread := unreadMessages(user.Username)
+	go paceReplay(user, unread, Event{Type: "replay_done", Data: len(unread)})
+}
+
+// 返回用户在会话中读过的最后一条消息 ID，没有已读消息时返回 0
+func lastReadID(username, sessionID string) int64 {
+	return readMark(username, sessionID)
+}
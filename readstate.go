@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 已读位置：每个用户在每个会话中读到的最后一条消息 ID，消息的已读状态由此推导，
+// 标记已读只需更新一个值，与会话消息数无关。readMu 为叶子锁，持有时不得再获取其他锁
+var (
+	readMarks = make(map[userSessionKey]int64)
+	readMu    sync.Mutex
+)
+
+// 已读位置文件：JSON Lines 追加写入每次推进，加载时取每个用户会话的最大值，
+// 因此写入顺序无关紧要。启用消息存储时默认为 MESSAGES_FILE 加 .read 后缀
+var (
+	readMarksFile string
+	readFileMu    sync.Mutex
+)
+
+// 已读位置文件中的一行
+type readMarkRecord struct {
+	User    string `json:"user"`
+	Session string `json:"session"`
+	UpTo    int64  `json:"up_to"`
+}
+
+// 用户在会话中的已读位置，没有已读消息时为 0
+func readMark(username, sessionID string) int64 {
+	readMu.Lock()
+	defer readMu.Unlock()
+	return readMarks[userSessionKey{user: username, session: sessionID}]
+}
+
+// 推进已读位置，只前进不后退，返回原位置及是否有变化
+func advanceReadMark(username, sessionID string, upTo int64) (int64, bool) {
+	key := userSessionKey{user: username, session: sessionID}
+	readMu.Lock()
+	defer readMu.Unlock()
+	prev := readMarks[key]
+	if upTo <= prev {
+		return prev, false
+	}
+	readMarks[key] = upTo
+	return prev, true
+}
+
+// 追加写入一次已读位置推进，失败时记录日志
+func saveReadMark(username, sessionID string, upTo int64) {
+	readFileMu.Lock()
+	defer readFileMu.Unlock()
+	if readMarksFile == "" {
+		return
+	}
+	f, err := os.OpenFile(readMarksFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("保存已读位置失败: %v", err)
+		return
+	}
+	defer f.Close()
+	line, _ := json.Marshal(readMarkRecord{User: username, Session: sessionID, UpTo: upTo})
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("保存已读位置失败: %v", err)
+	}
+}
+
+// 从文件加载已读位置，并把文件压缩为每个用户会话一行
+func loadReadMarks(path string) {
+	readFileMu.Lock()
+	defer readFileMu.Unlock()
+	readMarksFile = path
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("读取已读位置失败: %v", err)
+		}
+		return
+	}
+	readMu.Lock()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec readMarkRecord
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		key := userSessionKey{user: rec.User, session: rec.Session}
+		if rec.UpTo > readMarks[key] {
+			readMarks[key] = rec.UpTo
+		}
+	}
+	var buf []byte
+	for key, id := range readMarks {
+		line, _ := json.Marshal(readMarkRecord{User: key.user, Session: key.session, UpTo: id})
+		buf = append(append(buf, line...), '\n')
+	}
+	readMu.Unlock()
+	f.Close()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		log.Printf("压缩已读位置文件失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("压缩已读位置文件失败: %v", err)
+	}
+}
+
+// 会话内各成员的已读位置快照
+func sessionReadMarks(sessionID string) map[string]int64 {
+	readMu.Lock()
+	defer readMu.Unlock()
+	res := make(map[string]int64)
+	for key, id := range readMarks {
+		if key.session == sessionID {
+			res[key.user] = id
+		}
+	}
+	return res
+}
+
+// 用户在各会话的已读位置快照
+func userReadMarks(username string) map[string]int64 {
+	readMu.Lock()
+	defer readMu.Unlock()
+	res := make(map[string]int64)
+	for key, id := range readMarks {
+		if key.user == username {
+			res[key.session] = id
+		}
+	}
+	return res
+}
+
+// 由已读位置推导消息的已读用户（不含发送者与看不到该消息的人），附加消息上显式记录的读者（如系统消息的操作者）
+func readersOf(msg Message, marks map[string]int64) []string {
+	var res []string
+	for name, id := range marks {
+		if name != msg.From && id >= msg.ID && visibleTo(msg, name) && !containsString(msg.ReadBy, name) {
+			res = append(res, name)
+		}
+	}
+	if len(res) == 0 {
+		return msg.ReadBy
+	}
+	return append(append([]string(nil), msg.ReadBy...), res...)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// 为返回给客户端的消息填充推导出的已读状态
+func withReadState(list []Message) {
+	marks := make(map[string]map[string]int64)
+	for i := range list {
+		m, ok := marks[list[i].To]
+		if !ok {
+			m = sessionReadMarks(list[i].To)
+			marks[list[i].To] = m
+		}
+		list[i].ReadBy = readersOf(list[i], m)
+		list[i].IsRead = len(list[i].ReadBy) > 0
+	}
+}
+
+// 刷新会话中 ID 在 (from, to] 内、因用户新读过而已读状态改变的消息的 UpdatedAt，
+// 让增量同步能返回已读变化；消息按 ID 有序，范围外的消息不会被访问
+func touchReadRange(username, sessionID string, from, to int64) {
+	now := time.Now()
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	start := sort.Search(len(messages), func(i int) bool { return messages[i].ID > from })
+	for i := start; i < len(messages) && messages[i].ID <= to; i++ {
+		m := &messages[i]
+		if m.To == sessionID && m.From != username && visibleTo(*m, username) {
+			m.UpdatedAt = now
+		}
+	}
+}
+
+// 将旧数据中逐条记录的已读列表迁移为已读位置，系统消息保留显式读者（调用方需持有 msgMu）
+func migrateReadByLocked() {
+	readMu.Lock()
+	defer readMu.Unlock()
+	for i, msg := range messages {
+		if len(msg.ReadBy) == 0 || msg.IsSystem {
+			continue
+		}
+		for _, name := range msg.ReadBy {
+			key := userSessionKey{user: name, session: msg.To}
+			if msg.ID > readMarks[key] {
+				readMarks[key] = msg.ID
+			}
+		}
+		messages[i].ReadBy = nil
+		messages[i].IsRead = false
+	}
+}
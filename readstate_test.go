@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadBroadcastsClampedMarkOnce(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	seedMessage(Message{From: "alice", To: "room", Content: "one"})
+	last := seedMessage(Message{From: "alice", To: "room", Content: "two"})
+	alice := connect(t, "alice")
+	bob := connect(t, "bob")
+
+	bob.send(map[string]interface{}{"type": "read", "session_id": "room", "id": 999})
+	var ev ReadEvent
+	_ = json.Unmarshal(alice.event("read"), &ev)
+	if ev.User != "bob" || ev.UpTo != last.ID {
+		t.Fatalf("read event %+v, want up_to %d", ev, last.ID)
+	}
+	bob.send(map[string]interface{}{"type": "read", "session_id": "room", "id": last.ID})
+	alice.noEvent("read", 100*time.Millisecond)
+}
+
+func TestReadBumpsUpdatedAtForDeltaSync(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	old := seedMessage(Message{From: "alice", To: "room", Content: "one", Timestamp: time.Now().Add(-time.Hour)})
+	since := time.Now()
+	time.Sleep(time.Millisecond)
+
+	readSession("bob", "room", old.ID)
+	m, _ := findMessage(old.ID)
+	if !m.UpdatedAt.After(since) {
+		t.Fatalf("updated_at %v not after %v", m.UpdatedAt, since)
+	}
+}
+
+func TestWhisperReadersExcludeNonRecipients(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob", "carol")
+	w := seedMessage(Message{From: "alice", To: "room", Content: "psst", Whisper: []string{"bob"}})
+	readSession("bob", "room", w.ID)
+	readSession("carol", "room", w.ID)
+
+	list := []Message{w}
+	withReadState(list)
+	if len(list[0].ReadBy) != 1 || list[0].ReadBy[0] != "bob" {
+		t.Fatalf("read_by %v, want [bob]", list[0].ReadBy)
+	}
+}
+
+func TestReadMarksSurviveRestart(t *testing.T) {
+	resetState(t)
+	path := filepath.Join(t.TempDir(), "messages.read")
+	loadReadMarks(path)
+	t.Cleanup(func() { readMarksFile = "" })
+	newTestSession("room", true, "alice", "bob")
+	for i := 0; i < 3; i++ {
+		seedMessage(Message{From: "alice", To: "room", Content: "hi"})
+	}
+	readSession("bob", "room", 2)
+	readSession("bob", "room", 3)
+
+	readMu.Lock()
+	readMarks = make(map[userSessionKey]int64)
+	readMu.Unlock()
+	loadReadMarks(path)
+	if got := readMark("bob", "room"); got != 3 {
+		t.Fatalf("mark after reload %d, want 3", got)
+	}
+}
+
+// 已读位置推进一步的开销不随会话消息数增长
+func BenchmarkMarkRead(b *testing.B) {
+	for _, n := range []int{1000, 100000} {
+		b.Run(fmt.Sprintf("messages=%d", n), func(b *testing.B) {
+			resetState(b)
+			newTestSession("room", true, "alice", "bob")
+			msgMu.Lock()
+			for i := 1; i <= n; i++ {
+				messages = append(messages, Message{ID: int64(i), From: "alice", To: "room"})
+			}
+			msgID = int64(n) + 1
+			msgMu.Unlock()
+			key := userSessionKey{user: "bob", session: "room"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				readMu.Lock()
+				readMarks[key] = int64(n) - 1
+				readMu.Unlock()
+				markRead("bob", "room", int64(n))
+			}
+		})
+	}
+}
+
+// 以 unread_only 重连：只补推各会话中未读的他人消息，随后收到 replay_done
+func TestReconnectReplaysExactlyUnread(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	newTestSession("other", true, "alice", "bob")
+	newTestSession("elsewhere", true, "alice")
+	read := seedMessage(Message{From: "alice", To: "room", Content: "already read"})
+	u1 := seedMessage(Message{From: "alice", To: "room", Content: "unread 1"})
+	seedMessage(Message{From: "bob", To: "room", Content: "own message"})
+	u2 := seedMessage(Message{From: "alice", To: "other", Content: "unread 2"})
+	seedMessage(Message{From: "alice", To: "elsewhere", Content: "not a member"})
+	seedMessage(Message{From: "alice", To: "room", Content: "whisper", Whisper: []string{"carol"}})
+	markRead("bob", "room", read.ID)
+
+	bob := dialRaw(t, "bob")
+	bob.send(map[string]interface{}{"username": "bob", "unread_only": true})
+	var got []int64
+	for {
+		v := bob.next()
+		var typ string
+		_ = json.Unmarshal(v["type"], &typ)
+		if typ == "replay_done" {
+			var n int
+			_ = json.Unmarshal(v["data"], &n)
+			if n != 2 {
+				t.Fatalf("replay_done reported %d", n)
+			}
+			break
+		}
+		if typ == "" {
+			var id int64
+			_ = json.Unmarshal(v["id"], &id)
+			got = append(got, id)
+		}
+	}
+	if len(got) != 2 || got[0] != u1.ID || got[1] != u2.ID {
+		t.Fatalf("replayed %v, want [%d %d]", got, u1.ID, u2.ID)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// 无入站活动超过该时长的连接会被回收，0 表示关闭
+var idleTimeout = envDuration("IDLE_TIMEOUT", 30*time.Minute)
+
+// 读取最后活跃时间
+func (u *User) lastActive() time.Time {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.LastActive
+}
+
+// 定期回收空闲连接，ctx 取消时退出
+func reapIdle(ctx context.Context) {
+	if idleTimeout <= 0 {
+		return
+	}
+	interval := idleTimeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			reapOnce(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 执行一次空闲连接回收
+func reapOnce(now time.Time) {
+	userMu.Lock()
+	var idle []*User
+	for _, conns := range users {
+		for u := range conns {
+			if now.Sub(u.lastActive()) >= idleTimeout {
+				idle = append(idle, u)
+			}
+		}
+	}
+	userMu.Unlock()
+
+	for _, u := range idle {
+		log.Printf("回收空闲连接: user=%q ip=%s last_active=%s", u.Username, u.ip, u.lastActive().Format(time.RFC3339))
+		_ = u.WS.Close(CloseIdle, "idle timeout")
+	}
+}
+
+// 关闭所有在线连接
+func closeAll(code int, reason string) {
+	userMu.Lock()
+	var all []*User
+	for _, conns := range users {
+		for u := range conns {
+			all = append(all, u)
+		}
+	}
+	userMu.Unlock()
+	for _, u := range all {
+		_ = u.WS.Close(code, reason)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// 刷新控制事件内容：版本低于 MinVersion 的客户端应重新加载，为空时全部刷新
+type RefreshEvent struct {
+	MinVersion    string `json:"min_version,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	ServerVersion string `json:"server_version"`
+}
+
+// 向所有在线连接推送刷新事件，返回推送的连接数
+func broadcastRefresh(ev RefreshEvent) int {
+	userMu.Lock()
+	var all []*User
+	for _, conns := range users {
+		for u := range conns {
+			all = append(all, u)
+		}
+	}
+	userMu.Unlock()
+
+	n := 0
+	for _, u := range all {
+		if u.enqueueUrgent(Event{Type: "refresh", Data: ev}) {
+			n++
+		}
+	}
+	return n
+}
+
+// 通知客户端刷新（管理员）：POST /api/admin/refresh，请求体可选 {min_version, reason}
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req RefreshEvent
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req.ServerVersion = serverVersion
+	n := broadcastRefresh(req)
+	audit(adminActor(r), "refresh_clients", req.MinVersion, req.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"notified": n})
+}
@@ -0,0 +1,42 @@
+package main
+
+import "time"
+
+// 重连补发的分批大小与批次间隔，分批为 0 时一次性补发；批次之间实时消息照常投递
+var (
+	replayChunk    = envInt("REPLAY_CHUNK", 50)
+	replayInterval = envDuration("REPLAY_INTERVAL", 100*time.Millisecond)
+)
+
+// 分批补发消息，发送队列积压过半时推迟下一批，全部发完后发送 done 事件；连接断开即停止
+func paceReplay(u *User, list []Message, done Event) {
+	defer recoverLog("消息补发")
+	if replayChunk <= 0 || len(list) <= replayChunk {
+		for _, msg := range list {
+			u.enqueue(msg)
+		}
+		u.enqueue(done)
+		return
+	}
+
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+	for len(list) > 0 {
+		if len(u.send) <= cap(u.send)/2 {
+			n := min(replayChunk, len(list))
+			for _, msg := range list[:n] {
+				u.enqueue(msg)
+			}
+			list = list[n:]
+			if len(list) == 0 {
+				break
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-u.done:
+			return
+		}
+	}
+	u.enqueue(done)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 举报原因最长字符数
+const maxReportReason = 500
+
+// 是否向在线管理员推送新举报
+var reportNotifyAdmins = envBool("REPORT_NOTIFY_ADMINS", true)
+
+// 消息举报记录
+type Report struct {
+	ID        int64     `json:"id"`
+	Reporter  string    `json:"reporter"`
+	MessageID int64     `json:"message_id"`
+	SessionID string    `json:"session_id"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var (
+	reports   []Report // 待处理的举报，按 ID 升序
+	reportSeq int64
+	reportMu  sync.Mutex
+)
+
+// 处理 report 帧：举报可见的消息，同一用户对同一消息只记录一次
+func handleReport(user *User, data []byte) {
+	var req struct {
+		ID     int64  `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的举报")
+		return
+	}
+	msg, ok := findMessage(req.ID)
+	if !ok || !canView(msg, user.Username) {
+		sendError(user, "not_found", "消息不存在")
+		return
+	}
+
+	reportMu.Lock()
+	for _, r := range reports {
+		if r.Reporter == user.Username && r.MessageID == msg.ID {
+			reportMu.Unlock()
+			sendError(user, "already_reported", "你已举报过该消息")
+			return
+		}
+	}
+	reportSeq++
+	rep := Report{
+		ID:        reportSeq,
+		Reporter:  user.Username,
+		MessageID: msg.ID,
+		SessionID: msg.To,
+		Reason:    truncate(req.Reason, maxReportReason),
+		Timestamp: time.Now(),
+	}
+	reports = append(reports, rep)
+	reportMu.Unlock()
+
+	user.enqueue(Event{Type: "reported", Data: rep})
+	if reportNotifyAdmins {
+		notifyAdmins(Event{Type: "report", Data: rep})
+	}
+}
+
+// 向在线且已通过认证的管理员推送事件
+func notifyAdmins(ev Event) {
+	userMu.Lock()
+	defer userMu.Unlock()
+	for name, conns := range users {
+		if !isAdminUser(name) {
+			continue
+		}
+		for u := range conns {
+			if u.verifiedAdmin() {
+				u.enqueue(ev)
+			}
+		}
+	}
+}
+
+// 举报队列（管理员）：GET 列出待处理举报，DELETE ?id= 标记为已处理
+func reportsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		reportMu.Lock()
+		res := append(make([]Report, 0, len(reports)), reports...)
+		reportMu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !resolveReport(id) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		audit(adminActor(r), "resolve_report", strconv.FormatInt(id, 10), "")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// 从待处理队列移除举报
+func resolveReport(id int64) bool {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	for i, r := range reports {
+		if r.ID == id {
+			reports = append(reports[:i], reports[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// 清空举报队列，测试结束后恢复
+func resetReports(t *testing.T) {
+	reportMu.Lock()
+	saved, seq := reports, reportSeq
+	reports, reportSeq = nil, 0
+	reportMu.Unlock()
+	t.Cleanup(func() {
+		reportMu.Lock()
+		reports, reportSeq = saved, seq
+		reportMu.Unlock()
+	})
+}
+
+func listReports(t *testing.T) []Report {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/reports", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	reportsHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list reports returned %d", w.Code)
+	}
+	var list []Report
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestReportFiledAndListed(t *testing.T) {
+	resetState(t)
+	resetReports(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	newTestSession("room", true, "alice", "bob")
+	m := seedMessage(Message{From: "bob", To: "room", Content: "spam spam"})
+	alice := connect(t, "alice")
+
+	alice.send(map[string]interface{}{"type": "report", "id": m.ID, "reason": "spam"})
+	var rep Report
+	_ = json.Unmarshal(alice.event("reported"), &rep)
+	if rep.Reporter != "alice" || rep.MessageID != m.ID || rep.SessionID != "room" || rep.Reason != "spam" {
+		t.Fatalf("reported %+v", rep)
+	}
+
+	// 重复举报被拒绝，队列中只有一条
+	alice.send(map[string]interface{}{"type": "report", "id": m.ID, "reason": "again"})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "already_reported" {
+		t.Fatalf("duplicate report got %+v", info)
+	}
+
+	list := listReports(t)
+	if len(list) != 1 || list[0].ID != rep.ID || list[0].Reporter != "alice" || list[0].Timestamp.IsZero() {
+		t.Fatalf("pending reports %+v", list)
+	}
+}
+
+func TestReportsRequireAdmin(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+	w := httptest.NewRecorder()
+	reportsHandler(w, httptest.NewRequest(http.MethodGet, "/api/admin/reports", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got %d without token", w.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// 断开后恢复令牌的有效期，0 表示不签发
+var resumeTTL = envDuration("RESUME_TTL", 30*time.Second)
+
+// 恢复令牌对应的连接状态
+type resumeState struct {
+	username string
+	lastID   int64     // 断开时已分配的最大消息 ID，恢复时补发之后的消息
+	expires  time.Time // 连接仍在线时为零值，此时令牌不可用
+}
+
+var (
+	resumeTokens = make(map[string]*resumeState)
+	resumeMu     sync.Mutex
+)
+
+// 恢复结果事件内容
+type ResumedEvent struct {
+	Missed int `json:"missed"`
+}
+
+// 为新连接签发恢复令牌，断开后才可使用
+func issueResumeToken(username string) string {
+	if resumeTTL <= 0 {
+		return ""
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	token := hex.EncodeToString(b)
+
+	now := time.Now()
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	for t, st := range resumeTokens {
+		if !st.expires.IsZero() && now.After(st.expires) {
+			delete(resumeTokens, t)
+		}
+	}
+	resumeTokens[token] = &resumeState{username: username}
+	return token
+}
+
+// 连接断开时启用令牌并开始计时
+func releaseResumeToken(token string) {
+	if token == "" {
+		return
+	}
+	msgMu.Lock()
+	lastID := msgID - 1
+	msgMu.Unlock()
+
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	if st, ok := resumeTokens[token]; ok {
+		st.lastID = lastID
+		st.expires = time.Now().Add(resumeTTL)
+	}
+}
+
+// 作废尚未启用的令牌（连接未能注册时）
+func revokeResumeToken(token string) {
+	resumeMu.Lock()
+	delete(resumeTokens, token)
+	resumeMu.Unlock()
+}
+
+// 查看恢复令牌对应的用户但不消耗，供认证前确定用户名
+func peekResumeToken(token string, now time.Time) (resumeState, bool) {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	st, ok := resumeTokens[token]
+	if !ok || st.expires.IsZero() || now.After(st.expires) {
+		return resumeState{}, false
+	}
+	return *st, true
+}
+
+// 使用恢复令牌：令牌只能使用一次，过期或仍在线时无效
+func consumeResumeToken(token string, now time.Time) (resumeState, bool) {
+	resumeMu.Lock()
+	defer resumeMu.Unlock()
+	st, ok := resumeTokens[token]
+	if !ok || st.expires.IsZero() {
+		return resumeState{}, false
+	}
+	delete(resumeTokens, token)
+	if now.After(st.expires) {
+		return resumeState{}, false
+	}
+	return *st, true
+}
+
+// 补发断线期间用户所在会话中他人发送的消息，分批发完后发送 resumed 事件
+func replayMissed(user *User, after int64) {
+	joined := userSessions(user.Username)
+	msgMu.Lock()
+	var missed []Message
+	for _, msg := range messages {
+		if msg.ID > after && joined[msg.To] && msg.From != user.Username && !msg.Deleted && visibleTo(msg, user.Username) {
+			missed = append(missed, localize(msg, user.lang))
+		}
+	}
+	msgMu.Unlock()
+
+	go paceReplay(user, missed, Event{Type: "resumed", Data: ResumedEvent{Missed: len(missed)}})
+}
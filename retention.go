@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// 全局消息保留天数，0 表示永久保留；会话可通过 RetentionDays 单独设置
+var retentionDays = envInt("RETENTION_DAYS", 0)
+
+// 会话的实际保留时长：会话显式设置的值优先，未设置时继承全局值，0 表示永久保留
+func effectiveRetention(s *Session) time.Duration {
+	days := retentionDays
+	if s.RetentionDays != nil {
+		days = *s.RetentionDays
+	}
+	if days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// 定期删除超过保留期限的消息，ctx 取消时退出
+func sweepRetention(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !maintenance.Load() { // 维护期间暂停清理
+				retentionOnce(time.Now())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 执行一次保留期清理，按各会话的实际保留时长判断
+func retentionOnce(now time.Time) {
+	limits := make(map[string]time.Duration)
+	sessMu.Lock()
+	for id, s := range sessions {
+		if d := effectiveRetention(s); d > 0 {
+			limits[id] = d
+		}
+	}
+	sessMu.Unlock()
+	if len(limits) == 0 {
+		return
+	}
+
+	removed := make(map[int64]bool)
+	msgMu.Lock()
+	kept := messages[:0]
+	for _, msg := range messages {
+		if d, ok := limits[msg.To]; ok && now.Sub(msg.Timestamp) > d {
+			removed[msg.ID] = true
+			continue
+		}
+		kept = append(kept, msg)
+	}
+	for i := len(kept); i < len(messages); i++ {
+		messages[i] = Message{}
+	}
+	messages = kept
+	unpersistLocked(removed)
+	msgMu.Unlock()
+
+	if len(removed) > 0 {
+		flushStore()
+		log.Printf("清理过期消息: count=%d", len(removed))
+	}
+}
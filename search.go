@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 分页默认与最大条数
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// 匹配位置（字节偏移，左闭右开）
+type Offset struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// 搜索命中结果
+type SearchHit struct {
+	Message
+	Offsets []Offset `json:"offsets"`
+}
+
+// 解析 before/limit 分页参数，before 为 0 表示从最新一条开始
+func parsePage(r *http.Request, defLimit, maxLimit int) (before int64, limit int, ok bool) {
+	q := r.URL.Query()
+	limit = defLimit
+	if v := q.Get("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		before = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		limit = maxLimit
+	}
+	return before, limit, true
+}
+
+// 大小写不敏感地查找 query 在 content 中的所有出现位置（不重叠）
+func matchOffsets(content, query string) []Offset {
+	if query == "" {
+		return nil
+	}
+	var res []Offset
+	for i := 0; i < len(content); {
+		if end, ok := foldPrefix(content[i:], query); ok {
+			res = append(res, Offset{Start: i, End: i + end})
+			i += end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(content[i:])
+		i += size
+	}
+	return res
+}
+
+// 判断 s 是否以 prefix 开头（大小写不敏感），返回匹配部分在 s 中的字节长度
+func foldPrefix(s, prefix string) (int, bool) {
+	n := 0
+	for _, pr := range prefix {
+		if n >= len(s) {
+			return 0, false
+		}
+		sr, size := utf8.DecodeRuneInString(s[n:])
+		if !runeEqualFold(sr, pr) {
+			return 0, false
+		}
+		n += size
+	}
+	return n, true
+}
+
+// 按 Unicode 简单大小写折叠比较两个字符
+func runeEqualFold(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+// 搜索会话消息，结果按从新到旧分页返回
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	query := r.URL.Query().Get("q")
+	viewer := r.URL.Query().Get("user")
+	before, limit, ok := parsePage(r, defaultPageSize, maxPageSize)
+	if sessionID == "" || query == "" || !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res := make([]SearchHit, 0)
+	msgMu.Lock()
+	for i := len(messages) - 1; i >= 0 && len(res) < limit; i-- {
+		msg := messages[i]
+		if msg.To != sessionID || msg.Deleted || !visibleTo(msg, viewer) || (before > 0 && msg.ID >= before) {
+			continue
+		}
+		if offsets := matchOffsets(msg.Content, query); len(offsets) > 0 {
+			res = append(res, SearchHit{Message: msg, Offsets: offsets})
+		}
+	}
+	msgMu.Unlock()
+
+	list := make([]Message, len(res))
+	for i := range res {
+		list[i] = res[i].Message
+	}
+	withReadState(list)
+	for i := range res {
+		res[i].Message = list[i]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestMatchOffsets(t *testing.T) {
+	cases := []struct {
+		content, query string
+		want           []Offset
+	}{
+		{"hello world", "world", []Offset{{6, 11}}},
+		{"Go go GO", "go", []Offset{{0, 2}, {3, 5}, {6, 8}}},
+		{"aaaa", "aa", []Offset{{0, 2}, {2, 4}}}, // 不重叠
+		{"nothing here", "xyz", nil},
+		// 非 ASCII 内容按字节计算：每个汉字占 3 字节
+		{"你好世界，世界", "世界", []Offset{{6, 12}, {15, 21}}},
+		{"Straße STRASSE", "straße", []Offset{{0, 7}}},
+		{"ÉCOLE école", "école", []Offset{{0, 6}, {7, 13}}},
+	}
+	for _, c := range cases {
+		got := matchOffsets(c.content, c.query)
+		if len(got) != len(c.want) {
+			t.Errorf("matchOffsets(%q, %q) = %v, want %v", c.content, c.query, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("matchOffsets(%q, %q) = %v, want %v", c.content, c.query, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func searchRoom(t *testing.T, query string) []SearchHit {
+	t.Helper()
+	w := httptest.NewRecorder()
+	searchHandler(w, httptest.NewRequest(http.MethodGet, "/api/search?session_id=room&user=alice&q="+url.QueryEscape(query), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("search returned %d", w.Code)
+	}
+	var hits []SearchHit
+	if err := json.Unmarshal(w.Body.Bytes(), &hits); err != nil {
+		t.Fatal(err)
+	}
+	return hits
+}
+
+func TestSearchReturnsOffsets(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	single := seedMessage(Message{From: "alice", To: "room", Content: "Deploy finished"})
+	multi := seedMessage(Message{From: "alice", To: "room", Content: "deploy, DEPLOY, deploy!"})
+	seedMessage(Message{From: "alice", To: "room", Content: "unrelated"})
+	cjk := seedMessage(Message{From: "alice", To: "room", Content: "部署完成，deploy 了"})
+
+	hits := searchRoom(t, "deploy")
+	if len(hits) != 3 {
+		t.Fatalf("got %d hits", len(hits))
+	}
+	byID := make(map[int64][]Offset)
+	for _, h := range hits {
+		byID[h.ID] = h.Offsets
+	}
+	if got := byID[single.ID]; len(got) != 1 || got[0] != (Offset{0, 6}) {
+		t.Fatalf("single-hit offsets %v", got)
+	}
+	if got := byID[multi.ID]; len(got) != 3 || got[0] != (Offset{0, 6}) || got[1] != (Offset{8, 14}) || got[2] != (Offset{16, 22}) {
+		t.Fatalf("multi-hit offsets %v", got)
+	}
+	// "部署完成，" 共 15 字节
+	if got := byID[cjk.ID]; len(got) != 1 || got[0] != (Offset{15, 21}) {
+		t.Fatalf("non-ASCII offsets %v", got)
+	}
+	if hits[0].ID != cjk.ID {
+		t.Fatalf("hits not newest first: %d", hits[0].ID)
+	}
+}
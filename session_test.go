@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetSessionUnreadForUser(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	newTestSession("other", true, "alice", "bob")
+	first := seedMessage(Message{From: "alice", To: "room", Content: "one"})
+	seedMessage(Message{From: "alice", To: "room", Content: "two"})
+	seedMessage(Message{From: "alice", To: "other", Content: "elsewhere"})
+	markRead("bob", "room", first.ID)
+
+	get := func(query string) SessionDetail {
+		w := httptest.NewRecorder()
+		getSessionHandler(w, httptest.NewRequest(http.MethodGet, "/api/session?"+query, nil))
+		var d SessionDetail
+		_ = json.Unmarshal(w.Body.Bytes(), &d)
+		return d
+	}
+	if d := get("session_id=room&user=bob"); d.Unread != 1 {
+		t.Fatalf("bob unread %d, want 1", d.Unread)
+	}
+	if d := get("session_id=room&user=alice"); d.Unread != 0 {
+		t.Fatalf("sender unread %d, want 0", d.Unread)
+	}
+}
+
+// 管理员通过 PATCH 归档会话：发送被拒绝，历史仍可读取
+func TestArchivedSessionRejectsSendAllowsRead(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	newTestSession("room", true, "alice")
+	old := seedMessage(Message{From: "alice", To: "room", Content: "before archive"})
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/sessions?session_id=room", strings.NewReader(`{"archived":true}`))
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	patchSessionHandler(w, req)
+	var patched Session
+	_ = json.Unmarshal(w.Body.Bytes(), &patched)
+	if w.Code != http.StatusOK || !patched.Archived {
+		t.Fatalf("patch returned %d %+v", w.Code, patched)
+	}
+
+	alice := connect(t, "alice")
+	alice.send(map[string]string{"to": "room", "content": "after archive"})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "session_archived" {
+		t.Fatalf("got %+v", info)
+	}
+
+	w = httptest.NewRecorder()
+	messagesHandler(w, httptest.NewRequest(http.MethodGet, "/api/messages?session_id=room&user=alice", nil))
+	var page MessagePage
+	_ = json.Unmarshal(w.Body.Bytes(), &page)
+	if w.Code != http.StatusOK || len(page.Messages) != 1 || page.Messages[0].ID != old.ID {
+		t.Fatalf("read returned %d %+v", w.Code, page.Messages)
+	}
+}
+
+func TestPatchSessionRequiresAdmin(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	newTestSession("room", true, "alice")
+	w := httptest.NewRecorder()
+	patchSessionHandler(w, httptest.NewRequest(http.MethodPatch, "/api/sessions?session_id=room", strings.NewReader(`{"archived":true}`)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got %d without token", w.Code)
+	}
+	if s, _ := getSession("room"); s.Archived {
+		t.Fatal("session archived without admin token")
+	}
+}
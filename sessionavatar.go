@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// 允许上传的会话头像类型
+var sessionAvatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// 已上传的会话头像，按会话 ID 保存
+var (
+	sessionAvatars  = make(map[string]avatarEntry)
+	sessionAvatarMu sync.Mutex
+)
+
+// 会话头像：GET 返回已上传的图片，POST 上传新头像（multipart 字段 avatar），
+// 上传需管理员令牌，或以 user 参数指明的会话管理员（创建者）身份进行
+func sessionAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		sessionAvatarMu.Lock()
+		entry, ok := sessionAvatars[sessionID]
+		sessionAvatarMu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", entry.contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(entry.data)
+	case http.MethodPost:
+		uploadSessionAvatar(w, r, sessionID)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// 校验并保存上传的头像，更新会话的 Avatar 并通知成员
+func uploadSessionAvatar(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if !avatarsEnabled {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	current, ok := getSession(sessionID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	actor := adminActor(r)
+	if !hasAdminToken(r) {
+		actor = r.URL.Query().Get("user")
+		if actor == "" || !sessionMembers(sessionID)[actor] || memberRole(current, actor) != RoleAdmin {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	// 预留表单开销，超出部分由下面的大小校验拒绝
+	r.Body = http.MaxBytesReader(w, r.Body, int64(avatarMaxBytes)+64<<10)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, int64(avatarMaxBytes)+1))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(data) > avatarMaxBytes {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	// 按内容判断类型，不信任客户端声明的 Content-Type
+	ctype := http.DetectContentType(data)
+	if !sessionAvatarTypes[ctype] {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	sessionAvatarMu.Lock()
+	sessionAvatars[sessionID] = avatarEntry{data: data, contentType: ctype}
+	sessionAvatarMu.Unlock()
+
+	sessMu.Lock()
+	var res Session
+	s, ok := sessions[sessionID]
+	if ok {
+		s.Avatar = "/api/sessions/avatar?session_id=" + url.QueryEscape(sessionID)
+		res = *s
+	}
+	sessMu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	audit(actor, "update_session_avatar", sessionID, ctype)
+	broadcastEvent(sessionID, Event{Type: "session_updated", Data: res})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
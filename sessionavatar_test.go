@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func uploadAvatarAs(t *testing.T, user string) int {
+	t.Helper()
+	return uploadAvatarData(t, user, []byte("\x89PNG\r\n\x1a\n0000"))
+}
+
+func uploadAvatarData(t *testing.T, user string, data []byte) int {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("avatar", "a.png")
+	_, _ = part.Write(data)
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/sessions/avatar?session_id=room&user="+user, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	sessionAvatarHandler(w, req)
+	return w.Code
+}
+
+func TestSessionAvatarUploadBySessionRole(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	addSession(Session{ID: "room", Name: "room", IsGroup: true, Owner: "alice"})
+	addMember("room", "alice")
+	addMember("room", "bob")
+	t.Cleanup(func() {
+		sessionAvatarMu.Lock()
+		delete(sessionAvatars, "room")
+		sessionAvatarMu.Unlock()
+	})
+
+	if code := uploadAvatarAs(t, "bob"); code != http.StatusForbidden {
+		t.Fatalf("member upload returned %d", code)
+	}
+	if code := uploadAvatarAs(t, "mallory"); code != http.StatusForbidden {
+		t.Fatalf("non-member upload returned %d", code)
+	}
+	if code := uploadAvatarAs(t, "alice"); code != http.StatusOK {
+		t.Fatalf("owner upload returned %d", code)
+	}
+	if s, _ := getSession("room"); s.Avatar == "" {
+		t.Fatal("avatar not set")
+	}
+}
+
+// 按内容判断类型：声明为 .png 的文本或 SVG 一律拒绝，会话头像保持不变
+func TestSessionAvatarRejectsNonImage(t *testing.T) {
+	resetState(t)
+	addSession(Session{ID: "room", Name: "room", IsGroup: true, Owner: "alice"})
+	addMember("room", "alice")
+	t.Cleanup(func() {
+		sessionAvatarMu.Lock()
+		delete(sessionAvatars, "room")
+		sessionAvatarMu.Unlock()
+	})
+
+	for _, data := range [][]byte{
+		[]byte("just some text"),
+		[]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+	} {
+		if code := uploadAvatarData(t, "alice", data); code != http.StatusUnsupportedMediaType {
+			t.Fatalf("upload of %q returned %d", data[:10], code)
+		}
+	}
+	if s, _ := getSession("room"); s.Avatar != "" {
+		t.Fatalf("avatar set to %q", s.Avatar)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 软删除会话的恢复期，超过后由清理任务连同消息一起永久删除
+var sessionRecoveryWindow = envDuration("SESSION_RECOVERY_WINDOW", 7*24*time.Hour)
+
+// 软删除会话（管理员）：DELETE /api/session?session_id=
+func deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := getSession(sessionID); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// 先通知成员，删除后会话不再对外可见
+	broadcastEvent(sessionID, Event{Type: "session_deleted", Data: map[string]string{"session_id": sessionID}})
+
+	now := time.Now()
+	sessMu.Lock()
+	s, ok := sessions[sessionID]
+	if ok && s.DeletedAt == nil {
+		s.DeletedAt = &now
+	}
+	sessMu.Unlock()
+	audit(adminActor(r), "delete_session", sessionID, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 恢复软删除的会话（管理员）：POST /api/sessions/restore?session_id=，超过恢复期返回 410
+func restoreSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	sessionID := r.URL.Query().Get("session_id")
+	if sessionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sessMu.Lock()
+	s, ok := sessions[sessionID]
+	status := http.StatusOK
+	var res Session
+	switch {
+	case !ok || s.DeletedAt == nil:
+		status = http.StatusNotFound
+	case time.Since(*s.DeletedAt) > sessionRecoveryWindow:
+		status = http.StatusGone
+	default:
+		s.DeletedAt = nil
+		res = *s
+	}
+	sessMu.Unlock()
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+	audit(adminActor(r), "restore_session", sessionID, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// 定期永久删除超过恢复期的会话，ctx 取消时退出
+func purgeDeletedSessions(ctx context.Context) {
+	interval := sessionRecoveryWindow / 4
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !maintenance.Load() { // 维护期间暂不清除
+				purgeOnce(time.Now())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 执行一次清理：移除会话、成员关系及会话内的全部消息
+func purgeOnce(now time.Time) {
+	var expired []string
+	sessMu.Lock()
+	kept := sessionOrder[:0]
+	for _, id := range sessionOrder {
+		s := sessions[id]
+		if s.DeletedAt != nil && now.Sub(*s.DeletedAt) > sessionRecoveryWindow {
+			expired = append(expired, id)
+			delete(sessions, id)
+			delete(members, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	sessionOrder = kept
+	sessMu.Unlock()
+
+	for _, id := range expired {
+		removed := clearSessionMessages(id)
+		flushStore()
+		log.Printf("永久删除会话: session=%s messages=%d", id, len(removed))
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// 会话列表单页的最大条数与最大响应字节数，0 表示不限制；超出时通过 X-Next-Offset 分页
+var (
+	maxSessionsPage  = envInt("MAX_SESSIONS_PAGE", 200)
+	maxSessionsBytes = envInt("MAX_SESSIONS_BYTES", 1<<20)
+)
+
+// 解析 offset/limit 参数，limit 不超过 maxSessionsPage
+func parseSessionPage(r *http.Request) (offset, limit int, ok bool) {
+	q := r.URL.Query()
+	limit = maxSessionsPage
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if maxSessionsPage <= 0 || n < maxSessionsPage {
+			limit = n
+		}
+	}
+	return offset, limit, true
+}
+
+// 截取一页会话，同时限制条数与编码后的总字节数；返回下一页的偏移，没有更多时为 -1
+func pageSessions(list []SessionDetail, offset, limit int) ([]SessionDetail, int) {
+	if offset >= len(list) {
+		return []SessionDetail{}, -1
+	}
+	page := make([]SessionDetail, 0)
+	size := 2 // 数组的方括号
+	for i := offset; i < len(list); i++ {
+		if limit > 0 && len(page) >= limit {
+			return page, i
+		}
+		if maxSessionsBytes > 0 {
+			data, _ := json.Marshal(list[i])
+			// 至少返回一条，避免单个超大会话导致无法翻页
+			if len(page) > 0 && size+len(data)+1 > maxSessionsBytes {
+				return page, i
+			}
+			size += len(data) + 1
+		}
+		page = append(page, list[i])
+	}
+	return page, -1
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTouchSessionUpdatesOnlyTarget(t *testing.T) {
+	resetState(t)
+	newTestSession("a", true)
+	newTestSession("b", true)
+	at := time.Now()
+	if !touchSession("b", "latest", at) {
+		t.Fatal("touchSession reported missing session")
+	}
+	if touchSession("missing", "x", at) {
+		t.Fatal("touchSession succeeded for unknown session")
+	}
+	a, _ := getSession("a")
+	b, _ := getSession("b")
+	if a.LastMsg != "" || b.LastMsg != "latest" || !b.LastTime.Equal(at) {
+		t.Fatalf("a=%+v b=%+v", a, b)
+	}
+}
+
+// 按 ID 查找前的旧实现：线性遍历会话切片，用于对比
+func touchSessionLinear(list []Session, id, lastMsg string, at time.Time) bool {
+	for i := range list {
+		if list[i].ID == id {
+			list[i].LastMsg, list[i].LastTime = lastMsg, at
+			return true
+		}
+	}
+	return false
+}
+
+func benchmarkSessions(b *testing.B, n int) []Session {
+	resetState(b)
+	list := make([]Session, n)
+	for i := range list {
+		list[i] = Session{ID: fmt.Sprintf("s%d", i)}
+		addSession(list[i])
+	}
+	b.ResetTimer()
+	return list
+}
+
+func BenchmarkTouchSessionMap(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			benchmarkSessions(b, n)
+			id, at := fmt.Sprintf("s%d", n-1), time.Now()
+			for i := 0; i < b.N; i++ {
+				touchSession(id, "msg", at)
+			}
+		})
+	}
+}
+
+func BenchmarkTouchSessionLinear(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			list := benchmarkSessions(b, n)
+			id, at := fmt.Sprintf("s%d", n-1), time.Now()
+			for i := 0; i < b.N; i++ {
+				touchSessionLinear(list, id, "msg", at)
+			}
+		})
+	}
+}
+
+// 空结果输出 [] 而不是 null
+func TestEmptyResultsEncodeAsArray(t *testing.T) {
+	resetState(t)
+	get := func(h http.HandlerFunc, target string) string {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s returned %d", target, w.Code)
+		}
+		return strings.TrimSpace(w.Body.String())
+	}
+
+	if body := get(sessionsHandler, "/api/sessions"); body != "[]" {
+		t.Fatalf("empty session list: %s", body)
+	}
+	newTestSession("room", true, "alice")
+	if body := get(sessionsHandler, "/api/sessions?offset=5"); body != "[]" {
+		t.Fatalf("page past the end: %s", body)
+	}
+	if body := get(messagesHandler, "/api/messages?session_id=room&legacy=1"); body != "[]" {
+		t.Fatalf("legacy history: %s", body)
+	}
+	var page map[string]json.RawMessage
+	_ = json.Unmarshal([]byte(get(messagesHandler, "/api/messages?session_id=room")), &page)
+	if string(page["messages"]) != "[]" {
+		t.Fatalf("paged history messages: %s", page["messages"])
+	}
+}
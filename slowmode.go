@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	lastSendAt = make(map[userSessionKey]time.Time) // 用户在各会话最后一次发送时间
+	slowMu     sync.Mutex
+)
+
+// 慢速模式下距离下次可发送还需等待的秒数（向上取整），0 表示可以发送
+func slowModeRemaining(s Session, username string, now time.Time) int {
+	if s.SlowMode <= 0 {
+		return 0
+	}
+	slowMu.Lock()
+	last, ok := lastSendAt[userSessionKey{user: username, session: s.ID}]
+	slowMu.Unlock()
+	if !ok {
+		return 0
+	}
+	wait := time.Duration(s.SlowMode)*time.Second - now.Sub(last)
+	if wait <= 0 {
+		return 0
+	}
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// 记录发送时间
+func recordSend(username, sessionID string, at time.Time) {
+	slowMu.Lock()
+	defer slowMu.Unlock()
+	lastSendAt[userSessionKey{user: username, session: sessionID}] = at
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSlowModeRemaining(t *testing.T) {
+	resetState(t)
+	s := Session{ID: "room", SlowMode: 10}
+	now := time.Now()
+	if got := slowModeRemaining(s, "alice", now); got != 0 {
+		t.Fatalf("no previous send: %d", got)
+	}
+	recordSend("alice", "room", now)
+	for _, c := range []struct {
+		after time.Duration
+		want  int
+	}{
+		{0, 10},
+		{500 * time.Millisecond, 10}, // 向上取整
+		{3 * time.Second, 7},
+		{10 * time.Second, 0},
+	} {
+		if got := slowModeRemaining(s, "alice", now.Add(c.after)); got != c.want {
+			t.Errorf("after %v: %d, want %d", c.after, got, c.want)
+		}
+	}
+	if got := slowModeRemaining(Session{ID: "room"}, "alice", now); got != 0 {
+		t.Fatalf("slow mode off: %d", got)
+	}
+}
+
+// 冷却期内再次发送：错误事件携带剩余秒数，消息不会发出
+func TestSlowModeErrorReportsRemaining(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	sessMu.Lock()
+	sessions["room"].SlowMode = 30
+	sessMu.Unlock()
+	alice := connect(t, "alice")
+
+	alice.send(map[string]string{"to": "room", "content": "first"})
+	alice.message()
+	// 把上次发送时间提前 12 秒，剩余 18 秒
+	slowMu.Lock()
+	key := userSessionKey{user: "alice", session: "room"}
+	lastSendAt[key] = lastSendAt[key].Add(-12 * time.Second)
+	slowMu.Unlock()
+
+	alice.send(map[string]string{"to": "room", "content": "second"})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "slow_mode" || info.RetryAfter != 18 {
+		t.Fatalf("got %+v, want slow_mode with 18s remaining", info)
+	}
+}
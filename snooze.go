@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 会话通知暂停到期时间，与通知偏好共用 prefMu
+var snoozes = make(map[userSessionKey]time.Time)
+
+// 暂停结果事件内容，Until 为零值表示已取消暂停
+type SnoozeEvent struct {
+	SessionID string    `json:"session_id"`
+	Until     time.Time `json:"until"`
+}
+
+// 判断会话通知是否处于暂停中，到期后自动清除
+func snoozed(username, sessionID string, now time.Time) bool {
+	key := userSessionKey{user: username, session: sessionID}
+	prefMu.Lock()
+	defer prefMu.Unlock()
+	until, ok := snoozes[key]
+	if ok && !now.Before(until) {
+		delete(snoozes, key)
+		return false
+	}
+	return ok
+}
+
+// 处理 snooze 帧：until 为空或已过去时取消暂停
+func handleSnooze(user *User, data []byte) {
+	var req SnoozeEvent
+	if err := json.Unmarshal(data, &req); err != nil || req.SessionID == "" {
+		sendError(user, "bad_frame", "无法解析的暂停请求")
+		return
+	}
+	if !ensureMember(req.SessionID, user.Username) {
+		sendError(user, "not_member", "你不是该会话成员")
+		return
+	}
+
+	key := userSessionKey{user: user.Username, session: req.SessionID}
+	prefMu.Lock()
+	if req.Until.After(time.Now()) {
+		snoozes[key] = req.Until
+	} else {
+		delete(snoozes, key)
+		req.Until = time.Time{}
+	}
+	prefMu.Unlock()
+	user.enqueue(Event{Type: "snoozed", Data: req})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 用户发言统计
+type UserStats struct {
+	User       string         `json:"user"`
+	Total      int            `json:"total"`
+	PerSession map[string]int `json:"per_session"`
+	LastSent   time.Time      `json:"last_sent"`
+}
+
+var (
+	userStats = make(map[string]*UserStats)
+	statsMu   sync.Mutex
+)
+
+// 在接收消息时累加发言计数
+func countMessage(msg Message) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	st, ok := userStats[msg.From]
+	if !ok {
+		st = &UserStats{User: msg.From, PerSession: make(map[string]int)}
+		userStats[msg.From] = st
+	}
+	st.Total++
+	st.PerSession[msg.To]++
+	st.LastSent = msg.Timestamp
+}
+
+// 复制统计数据（调用方需持有 statsMu）
+func copyStatsLocked(st *UserStats) UserStats {
+	c := *st
+	c.PerSession = make(map[string]int, len(st.PerSession))
+	for id, n := range st.PerSession {
+		c.PerSession[id] = n
+	}
+	return c
+}
+
+// 查看用户发言统计（管理员）：指定 user 返回单个用户，否则按发言总数降序返回全部
+func userStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	username := r.URL.Query().Get("user")
+
+	statsMu.Lock()
+	var res interface{}
+	if username != "" {
+		st, ok := userStats[username]
+		if !ok {
+			st = &UserStats{User: username}
+		}
+		res = copyStatsLocked(st)
+	} else {
+		list := make([]UserStats, 0, len(userStats))
+		for _, st := range userStats {
+			list = append(list, copyStatsLocked(st))
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Total > list[j].Total })
+		res = list
+	}
+	statsMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func statsFor(t *testing.T, username string) UserStats {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/user-stats?user="+username, nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	userStatsHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("user-stats returned %d", w.Code)
+	}
+	var st UserStats
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestUserStatsCountSends(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	statsMu.Lock()
+	delete(userStats, "alice")
+	statsMu.Unlock()
+	newTestSession("room", true, "alice")
+	newTestSession("other", true, "alice")
+	alice := connect(t, "alice")
+
+	if st := statsFor(t, "alice"); st.Total != 0 {
+		t.Fatalf("before sending: %+v", st)
+	}
+	for i, to := range []string{"room", "room", "other"} {
+		alice.send(map[string]string{"to": to, "content": fmt.Sprintf("msg %d", i)})
+		alice.message()
+		if st := statsFor(t, "alice"); st.Total != i+1 {
+			t.Fatalf("after %d sends total is %d", i+1, st.Total)
+		}
+	}
+	st := statsFor(t, "alice")
+	if st.PerSession["room"] != 2 || st.PerSession["other"] != 1 || st.LastSent.IsZero() {
+		t.Fatalf("stats %+v", st)
+	}
+}
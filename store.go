@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 消息持久化接口
+type MessageStore interface {
+	Save(msg Message) error   // 写入消息，同 ID 的后写入覆盖先写入
+	Load() ([]Message, error) // 按写入顺序加载全部消息
+}
+
+// 当前使用的消息存储，nil 表示仅保存在内存
+var store MessageStore
+
+// 文件存储：以 JSON Lines 追加写入，加载时同 ID 以最后一条为准
+type fileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileStore) Save(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (s *fileStore) Load() ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var order []int64
+	byID := make(map[int64]Message)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if _, ok := byID[msg.ID]; !ok {
+			order = append(order, msg.ID)
+		}
+		byID[msg.ID] = msg
+	}
+	res := make([]Message, 0, len(order))
+	for _, id := range order {
+		res = append(res, byID[id])
+	}
+	return res, scanner.Err()
+}
+
+// 支持删除的存储，未实现时删除的消息仍保留在存储中
+type messageDeleter interface {
+	Delete(ids map[int64]bool) error
+}
+
+// 重写文件，去掉指定 ID 的全部记录
+func (s *fileStore) Delete(ids map[int64]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var kept []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		var head struct {
+			ID int64 `json:"id"`
+		}
+		if json.Unmarshal(line, &head) == nil && ids[head.ID] {
+			continue
+		}
+		kept = append(kept, line...)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, kept, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// 加密内容的前缀
+const encPrefix = "enc:v1:"
+
+// 加密存储包装：写入前以 AES-GCM 加密消息内容，读取时透明解密
+type encryptedStore struct {
+	inner MessageStore
+	aead  cipher.AEAD
+}
+
+// 创建加密存储，key 须为 16/24/32 字节
+func newEncryptedStore(inner MessageStore, key []byte) (*encryptedStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedStore{inner: inner, aead: aead}, nil
+}
+
+func (s *encryptedStore) encrypt(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plain), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *encryptedStore) decrypt(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encPrefix) {
+		return stored, nil // 兼容启用加密前写入的明文
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encPrefix))
+	if err != nil {
+		return "", err
+	}
+	n := s.aead.NonceSize()
+	if len(data) < n {
+		return "", errors.New("ciphertext too short")
+	}
+	plain, err := s.aead.Open(nil, data[:n], data[n:], nil)
+	return string(plain), err
+}
+
+func (s *encryptedStore) Save(msg Message) error {
+	var err error
+	if msg.Content, err = s.encrypt(msg.Content); err != nil {
+		return err
+	}
+	if msg.Quoted, err = s.encryptSnippet(msg.Quoted); err != nil {
+		return err
+	}
+	if len(msg.Quotes) > 0 {
+		quotes := make([]*QuotedSnippet, len(msg.Quotes))
+		for i, q := range msg.Quotes {
+			if quotes[i], err = s.encryptSnippet(q); err != nil {
+				return err
+			}
+		}
+		msg.Quotes = quotes
+	}
+	if msg.Forwarded != nil && msg.Forwarded.Context != nil {
+		f := *msg.Forwarded
+		if f.Context, err = s.encryptSnippet(f.Context); err != nil {
+			return err
+		}
+		msg.Forwarded = &f
+	}
+	if msg.Preview != nil {
+		p := *msg.Preview
+		for _, field := range []*string{&p.URL, &p.Title, &p.Description, &p.Image} {
+			if *field, err = s.encrypt(*field); err != nil {
+				return err
+			}
+		}
+		msg.Preview = &p
+	}
+	return s.inner.Save(msg)
+}
+
+// 加密片段内容，返回副本以免修改内存中的消息
+func (s *encryptedStore) encryptSnippet(q *QuotedSnippet) (*QuotedSnippet, error) {
+	if q == nil {
+		return nil, nil
+	}
+	c := *q
+	var err error
+	c.Content, err = s.encrypt(c.Content)
+	return &c, err
+}
+
+func (s *encryptedStore) Load() ([]Message, error) {
+	list, err := s.inner.Load()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Content, err = s.decrypt(list[i].Content); err != nil {
+			return nil, err
+		}
+		snippets := append([]*QuotedSnippet{list[i].Quoted}, list[i].Quotes...)
+		if f := list[i].Forwarded; f != nil {
+			snippets = append(snippets, f.Context)
+		}
+		for _, q := range snippets {
+			if q == nil {
+				continue
+			}
+			if q.Content, err = s.decrypt(q.Content); err != nil {
+				return nil, err
+			}
+		}
+		if p := list[i].Preview; p != nil {
+			for _, field := range []*string{&p.URL, &p.Title, &p.Description, &p.Image} {
+				if *field, err = s.decrypt(*field); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return list, nil
+}
+
+// 消息 ID 未加密，直接交给底层存储删除
+func (s *encryptedStore) Delete(ids map[int64]bool) error {
+	if d, ok := s.inner.(messageDeleter); ok {
+		return d.Delete(ids)
+	}
+	return nil
+}
+
+// 解析密钥：支持十六进制或 base64 编码
+func parseKey(v string) ([]byte, error) {
+	if key, err := hex.DecodeString(v); err == nil {
+		return key, nil
+	}
+	return base64.StdEncoding.DecodeString(v)
+}
+
+// 根据 MESSAGES_FILE / MESSAGE_KEY 初始化存储并加载历史消息
+func initStore() error {
+	path := os.Getenv("MESSAGES_FILE")
+	if path == "" {
+		return nil
+	}
+	var s MessageStore = &fileStore{path: path}
+	if v := os.Getenv("MESSAGE_KEY"); v != "" {
+		key, err := parseKey(v)
+		if err != nil {
+			return err
+		}
+		if s, err = newEncryptedStore(s, key); err != nil {
+			return err
+		}
+	}
+
+	list, err := s.Load()
+	if err != nil {
+		return err
+	}
+	readPath := os.Getenv("READ_MARKS_FILE")
+	if readPath == "" {
+		readPath = path + ".read"
+	}
+	loadReadMarks(readPath)
+	prefsPath := os.Getenv("PREFS_FILE")
+	if prefsPath == "" {
+		prefsPath = path + ".prefs"
+	}
+	loadPrefs(prefsPath)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	msgMu.Lock()
+	messages = list
+	for _, msg := range list {
+		if msg.ID >= msgID {
+			msgID = msg.ID + 1
+		}
+	}
+	migrateReadByLocked()
+	trackMessagesLocked(list...)
+	msgMu.Unlock()
+	if storeBuffer {
+		s = &bufferedStore{inner: s}
+	}
+	store = s
+	return nil
+}
+
+// 待执行的存储写入：在修改消息的同一把 msgMu 内入队，按入队顺序写出，
+// 同一消息并发的多次修改到达存储的顺序与内存中一致
+type storeOp struct {
+	save []Message
+	del  map[int64]bool
+}
+
+var (
+	storeOps     []storeOp
+	storeOpsMu   sync.Mutex // 叶子锁，可在持有 msgMu 时获取
+	storeWriteMu sync.Mutex // 串行化写出
+)
+
+// 将消息快照加入写入队列（调用方需持有 msgMu），随后须调用 flushStore
+func persistLocked(msgs ...Message) {
+	if store == nil || len(msgs) == 0 {
+		return
+	}
+	storeOpsMu.Lock()
+	storeOps = append(storeOps, storeOp{save: append([]Message(nil), msgs...)})
+	storeOpsMu.Unlock()
+}
+
+// 将删除加入写入队列（调用方需持有 msgMu），随后须调用 flushStore
+func unpersistLocked(ids map[int64]bool) {
+	if _, ok := store.(messageDeleter); !ok || len(ids) == 0 {
+		return
+	}
+	storeOpsMu.Lock()
+	storeOps = append(storeOps, storeOp{del: ids})
+	storeOpsMu.Unlock()
+}
+
+// 按顺序写出队列中的全部操作，失败时记录日志；返回时调用方入队的操作均已写出
+func flushStore() {
+	storeWriteMu.Lock()
+	defer storeWriteMu.Unlock()
+	for {
+		storeOpsMu.Lock()
+		ops := storeOps
+		storeOps = nil
+		storeOpsMu.Unlock()
+		if len(ops) == 0 {
+			return
+		}
+		for _, op := range ops {
+			applyStoreOp(op)
+		}
+	}
+}
+
+func applyStoreOp(op storeOp) {
+	if store == nil {
+		return
+	}
+	if op.del != nil {
+		if err := store.(messageDeleter).Delete(op.del); err != nil {
+			log.Printf("删除持久化消息失败: count=%d err=%v", len(op.del), err)
+		}
+		return
+	}
+	for _, msg := range op.save {
+		if err := store.Save(msg); err != nil {
+			log.Printf("持久化消息失败: id=%d err=%v", msg.ID, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 记录写入顺序的存储，每次写入略作停顿以放大并发乱序
+type recordingStore struct {
+	mu    sync.Mutex
+	saves []Message
+}
+
+func (s *recordingStore) Save(msg Message) error {
+	time.Sleep(50 * time.Microsecond)
+	s.mu.Lock()
+	s.saves = append(s.saves, msg)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *recordingStore) Load() ([]Message, error) { return nil, nil }
+
+func TestConcurrentUpdatesReachStoreInOrder(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	m := seedMessage(Message{From: "alice", To: "room", Streaming: true})
+	rec := &recordingStore{}
+	store = rec
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			updateMessage(m.ID, func(msg *Message) string {
+				msg.Content += "x"
+				return ""
+			})
+		}()
+	}
+	wg.Wait()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.saves) != n {
+		t.Fatalf("%d saves, want %d", len(rec.saves), n)
+	}
+	for i, s := range rec.saves {
+		if len(s.Content) != i+1 {
+			t.Fatalf("save %d has %d chars: writes reached the store out of order", i, len(s.Content))
+		}
+	}
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	key, err := parseKey(strings.Repeat("ab", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newEncryptedStore(&fileStore{path: path}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoted := &QuotedSnippet{From: "bob", Content: "quoted secret"}
+	msg := Message{ID: 1, From: "alice", To: "room", Content: "secret plan", Quoted: quoted,
+		Preview: &LinkPreview{URL: "https://example.com/plan", Title: "plan title"}}
+	if err := s.Save(msg); err != nil {
+		t.Fatal(err)
+	}
+	// 启用加密前写入的明文仍可读取
+	if err := (&fileStore{path: path}).Save(Message{ID: 2, From: "alice", To: "room", Content: "legacy plaintext"}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	for _, plain := range []string{"secret plan", "quoted secret", "plan title", "example.com"} {
+		if strings.Contains(string(raw), plain) {
+			t.Fatalf("stored form contains %q: %s", plain, raw)
+		}
+	}
+	if !strings.Contains(string(raw), encPrefix) {
+		t.Fatalf("stored form is not ciphertext: %s", raw)
+	}
+	if quoted.Content != "quoted secret" {
+		t.Fatal("Save modified the in-memory snippet")
+	}
+
+	list, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Content != "secret plan" || list[0].Quoted.Content != "quoted secret" ||
+		list[0].Preview.Title != "plan title" || list[1].Content != "legacy plaintext" {
+		t.Fatalf("loaded %+v", list)
+	}
+
+	// 密钥不符时加载失败，而不是返回密文
+	other, _ := newEncryptedStore(&fileStore{path: path}, []byte(strings.Repeat("k", 32)))
+	if _, err := other.Load(); err == nil {
+		t.Fatal("loaded with the wrong key")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 存储不可用时的降级配置：缓冲写入并按指数退避重试
+var (
+	storeBuffer    = envBool("STORE_BUFFER", false)
+	storeBufferMax = envInt("STORE_BUFFER_MAX", 10000) // 积压达到该数量时合并同一消息的多次写入
+	storeRetryMin  = envDuration("STORE_RETRY_MIN", time.Second)
+	storeRetryMax  = envDuration("STORE_RETRY_MAX", time.Minute)
+)
+
+// 积压的一次写入：保存一条消息，或删除一组消息
+type bufferedOp struct {
+	msg Message
+	del map[int64]bool
+}
+
+// 带缓冲的存储：底层写入或删除失败时先保存在内存，后台重试成功后按原顺序补写
+type bufferedStore struct {
+	inner    MessageStore
+	mu       sync.Mutex
+	pending  []bufferedOp
+	retrying bool
+}
+
+func (s *bufferedStore) Save(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 已有积压时直接排队，保证同一消息的多次写入不会乱序
+	if len(s.pending) == 0 {
+		err := s.inner.Save(msg)
+		if err == nil {
+			return nil
+		}
+		log.Printf("存储不可用，进入降级模式: err=%v", err)
+	}
+	s.enqueueLocked(bufferedOp{msg: msg})
+	return nil
+}
+
+func (s *bufferedStore) Load() ([]Message, error) {
+	return s.inner.Load()
+}
+
+// 删除与写入排在同一队列中，存储恢复后按原顺序执行，已删除的消息不会在重启后复活
+func (s *bufferedStore) Delete(ids map[int64]bool) error {
+	d, ok := s.inner.(messageDeleter)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		err := d.Delete(ids)
+		if err == nil {
+			return nil
+		}
+		log.Printf("存储不可用，进入降级模式: err=%v", err)
+	}
+	s.enqueueLocked(bufferedOp{del: ids})
+	return nil
+}
+
+// 加入积压并确保后台重试在运行；积压过多时先合并，消息不会被丢弃
+func (s *bufferedStore) enqueueLocked(op bufferedOp) {
+	s.pending = append(s.pending, op)
+	if storeBufferMax > 0 && len(s.pending) > storeBufferMax {
+		s.compactLocked()
+	}
+	if !s.retrying {
+		s.retrying = true
+		go s.retryLoop()
+	}
+}
+
+// 去掉会被后续写入覆盖或删除的旧写入：加载时同 ID 以最后一条为准，删除会去掉全部记录，
+// 因此只需保留每条消息最后一次操作
+func (s *bufferedStore) compactLocked() {
+	covered := make(map[int64]bool)
+	kept := make([]bufferedOp, 0, len(s.pending))
+	for i := len(s.pending) - 1; i >= 0; i-- {
+		op := s.pending[i]
+		if op.del != nil {
+			for id := range op.del {
+				covered[id] = true
+			}
+		} else if covered[op.msg.ID] {
+			continue
+		} else {
+			covered[op.msg.ID] = true
+		}
+		kept = append(kept, op)
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	if len(kept) > storeBufferMax {
+		log.Printf("存储积压超过上限: backlog=%d max=%d", len(kept), storeBufferMax)
+	}
+	s.pending = kept
+}
+
+// 后台重试积压的写入，全部写完后退出
+func (s *bufferedStore) retryLoop() {
+	backoff := storeRetryMin
+	for {
+		time.Sleep(backoff)
+		if s.flush() {
+			log.Printf("存储已恢复，积压消息已写入")
+			return
+		}
+		if backoff *= 2; backoff > storeRetryMax {
+			backoff = storeRetryMax
+		}
+	}
+}
+
+// 按顺序执行积压的写入与删除，遇到失败即停止；全部完成时返回 true
+func (s *bufferedStore) flush() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) > 0 {
+		op := s.pending[0]
+		var err error
+		if op.del != nil {
+			err = s.inner.(messageDeleter).Delete(op.del)
+		} else {
+			err = s.inner.Save(op.msg)
+		}
+		if err != nil {
+			return false
+		}
+		s.pending = s.pending[1:]
+	}
+	s.pending = nil
+	s.retrying = false
+	return true
+}
+
+// 积压待写入的消息数
+func (s *bufferedStore) backlog() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+// 健康状态
+type Health struct {
+	Status       string `json:"status"` // ok 或 degraded
+	StoreBacklog int    `json:"store_backlog"`
+}
+
+// 健康检查：GET /api/health，存储降级时仍返回 200，由 status 字段体现
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	h := Health{Status: "ok"}
+	if bs, ok := store.(*bufferedStore); ok {
+		if h.StoreBacklog = bs.backlog(); h.StoreBacklog > 0 {
+			h.Status = "degraded"
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h)
+}
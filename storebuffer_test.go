@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 可切换故障的存储，按同 ID 覆盖、删除去掉记录的语义保存内容
+type flakyStore struct {
+	mu   sync.Mutex
+	down bool
+	msgs map[int64]Message
+}
+
+var errStoreDown = errors.New("store down")
+
+func (s *flakyStore) Save(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errStoreDown
+	}
+	s.msgs[msg.ID] = msg
+	return nil
+}
+
+func (s *flakyStore) Load() ([]Message, error) { return nil, nil }
+
+func (s *flakyStore) Delete(ids map[int64]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errStoreDown
+	}
+	for id := range ids {
+		delete(s.msgs, id)
+	}
+	return nil
+}
+
+func (s *flakyStore) setDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func fastRetry(t *testing.T) {
+	min, max := storeRetryMin, storeRetryMax
+	storeRetryMin, storeRetryMax = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { storeRetryMin, storeRetryMax = min, max })
+}
+
+func health(t *testing.T) Health {
+	t.Helper()
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	var h Health
+	_ = json.Unmarshal(w.Body.Bytes(), &h)
+	return h
+}
+
+func TestBufferedStoreReplaysSavesAndDeletesAfterRecovery(t *testing.T) {
+	resetState(t)
+	fastRetry(t)
+	inner := &flakyStore{msgs: make(map[int64]Message), down: true}
+	bs := &bufferedStore{inner: inner}
+	store = bs
+
+	_ = bs.Save(Message{ID: 1, Content: "one"})
+	_ = bs.Save(Message{ID: 2, Content: "two"})
+	_ = bs.Delete(map[int64]bool{1: true})
+	_ = bs.Save(Message{ID: 2, Content: "two, edited"})
+	if h := health(t); h.Status != "degraded" || h.StoreBacklog == 0 {
+		t.Fatalf("health while down %+v", h)
+	}
+
+	inner.setDown(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for bs.backlog() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if h := health(t); h.Status != "ok" {
+		t.Fatalf("health after recovery %+v", h)
+	}
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	if _, ok := inner.msgs[1]; ok {
+		t.Fatal("deleted message came back")
+	}
+	if inner.msgs[2].Content != "two, edited" {
+		t.Fatalf("message 2 is %+v", inner.msgs[2])
+	}
+}
+
+func TestBufferedStoreCompactsInsteadOfDropping(t *testing.T) {
+	resetState(t)
+	max := storeBufferMax
+	storeBufferMax = 2
+	t.Cleanup(func() { storeBufferMax = max })
+	inner := &flakyStore{msgs: make(map[int64]Message), down: true}
+	bs := &bufferedStore{inner: inner, retrying: true} // 不启动后台重试
+
+	for i := 0; i < 5; i++ {
+		if err := bs.Save(Message{ID: 1, Content: string(rune('a' + i))}); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+	if err := bs.Save(Message{ID: 2}); err != nil {
+		t.Fatalf("save 2: %v", err)
+	}
+	if n := bs.backlog(); n != 2 {
+		t.Fatalf("backlog %d, want 2", n)
+	}
+	inner.setDown(false)
+	if !bs.flush() || inner.msgs[1].Content != "e" {
+		t.Fatalf("after flush %+v", inner.msgs)
+	}
+}
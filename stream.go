@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// 流式追加事件内容
+type ContentDelta struct {
+	ID        int64  `json:"id"`
+	SessionID string `json:"session_id"`
+	Chunk     string `json:"chunk"`
+	Content   string `json:"content,omitempty"` // 转换后的内容不再是原内容加片段时（如表情短码补全）下发完整内容
+}
+
+// 流式消息转换前的原始内容（由 msgMu 保护）：内容管道作用于拼接后的完整原文，
+// 片段边界的空白与跨片段的短码不受影响。重启后缺失时以已存储的内容为原文
+var streamRaw = make(map[int64]string)
+
+// 追加片段后的原文与转换后的内容（调用方需持有 msgMu）
+func appendedContentLocked(m Message, chunk string) (raw, content string) {
+	raw, ok := streamRaw[m.ID]
+	if !ok {
+		raw = m.Content
+	}
+	raw += chunk
+	return raw, applyTransforms(contentPipeline, raw)
+}
+
+// 修改指定的未删除消息并刷新 UpdatedAt、写入存储，fn 返回非空错误码时放弃修改
+func updateMessage(id int64, fn func(msg *Message) string) (Message, string) {
+	msg, code := updateMessageLocked(id, fn)
+	flushStore()
+	return msg, code
+}
+
+func updateMessageLocked(id int64, fn func(msg *Message) string) (Message, string) {
+	msgMu.Lock()
+	defer msgMu.Unlock()
+	for i := range messages {
+		if messages[i].ID != id {
+			continue
+		}
+		m := messages[i]
+		if m.Deleted {
+			return Message{}, "not_found"
+		}
+		if code := fn(&m); code != "" {
+			return Message{}, code
+		}
+		m.UpdatedAt = time.Now()
+		messages[i] = m
+		persistLocked(m)
+		return m, ""
+	}
+	return Message{}, "not_found"
+}
+
+// 处理 append / complete 帧：仅原发送者可向流式消息追加内容，完成后不再允许追加
+func handleAppend(user *User, data []byte, complete bool) {
+	var req struct {
+		ID    int64  `json:"id"`
+		Chunk string `json:"chunk"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的追加请求")
+		return
+	}
+
+	// 与直接发送相同：须仍是会话成员，会话未归档，追加后的完整内容须通过全部内容校验
+	cur, ok := findMessage(req.ID)
+	if !ok {
+		sendError(user, "not_found", "消息不存在")
+		return
+	}
+	s, ok := getSession(cur.To)
+	if !ok || !ensureMember(cur.To, user.Username) {
+		sendError(user, "not_member", "你不是该会话成员")
+		return
+	}
+	if s.Archived && !s.AutoArchived {
+		sendError(user, "session_archived", "会话已归档，无法发送消息")
+		return
+	}
+	if !complete {
+		next := cur
+		msgMu.Lock()
+		_, next.Content = appendedContentLocked(cur, req.Chunk)
+		msgMu.Unlock()
+		if code, text := checkAppended(next); code != "" {
+			sendError(user, code, text)
+			return
+		}
+		if err := messageValidator.Validate(next, s); err != nil {
+			sendError(user, "rejected", err.Error())
+			return
+		}
+	}
+	if s.Archived {
+		reviveSession(cur.To)
+	}
+
+	var rejected, prev string
+	msg, code := updateMessage(req.ID, func(m *Message) string {
+		switch {
+		case m.From != user.Username:
+			return "forbidden"
+		case !m.Streaming:
+			return "not_streaming"
+		}
+		if complete {
+			m.Streaming = false
+			delete(streamRaw, m.ID)
+			return ""
+		}
+		// 校验之后可能有其他连接追加过，按实际内容再检查一次
+		next := *m
+		raw, content := appendedContentLocked(*m, req.Chunk)
+		next.Content = content
+		if c, text := checkAppended(next); c != "" {
+			rejected = text
+			return c
+		}
+		prev, m.Content = m.Content, content
+		streamRaw[m.ID] = raw
+		return ""
+	})
+	switch code {
+	case "":
+	case "not_found":
+		sendError(user, code, "消息不存在")
+		return
+	case "forbidden":
+		sendError(user, code, "只能追加自己发送的消息")
+		return
+	case "not_streaming":
+		sendError(user, code, "消息已完成，无法继续追加")
+		return
+	default:
+		sendError(user, code, rejected)
+		return
+	}
+
+	if complete {
+		broadcastEvent(msg.To, Event{Type: "content_complete", Data: ContentDelta{ID: msg.ID, SessionID: msg.To}})
+		return
+	}
+	delta := ContentDelta{ID: msg.ID, SessionID: msg.To}
+	if strings.HasPrefix(msg.Content, prev) {
+		delta.Chunk = msg.Content[len(prev):]
+	} else {
+		delta.Content = msg.Content
+	}
+	broadcastEvent(msg.To, Event{Type: "content_delta", Data: delta})
+}
+
+// 追加后内容的长度、行数、@ 人数与元数据检查；直接发送的消息受单帧大小限制，追加后也不得超过
+func checkAppended(msg Message) (code, text string) {
+	if maxFrameBytes > 0 && len(msg.Content) > maxFrameBytes {
+		return "too_long", "消息内容过长"
+	}
+	if code, text := checkFormatting(msg.Content); code != "" {
+		return code, text
+	}
+	return checkMeta(msg.Meta)
+}
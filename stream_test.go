@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 发起一条流式消息，返回其 ID
+func startStream(t *testing.T, c *testClient, session, content string) int64 {
+	t.Helper()
+	c.send(map[string]interface{}{"to": session, "content": content, "streaming": true})
+	msg := c.message()
+	if !msg.Streaming {
+		t.Fatalf("message not streaming: %+v", msg)
+	}
+	return msg.ID
+}
+
+func appendError(t *testing.T, c *testClient, id int64, chunk string) string {
+	t.Helper()
+	c.send(map[string]interface{}{"type": "append", "id": id, "chunk": chunk})
+	var info ErrorInfo
+	_ = json.Unmarshal(c.event("error"), &info)
+	return info.Code
+}
+
+func TestAppendChecksCombinedContent(t *testing.T) {
+	resetState(t)
+	lines, mentionsCap := maxLines, maxMentions
+	maxLines, maxMentions = 3, 10
+	t.Cleanup(func() { maxLines, maxMentions = lines, mentionsCap })
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+	id := startStream(t, alice, "room", "a")
+
+	alice.send(map[string]interface{}{"type": "append", "id": id, "chunk": "\nb"})
+	var delta ContentDelta
+	_ = json.Unmarshal(alice.event("content_delta"), &delta)
+	if delta.Chunk != "\nb" {
+		t.Fatalf("delta %+v", delta)
+	}
+	if code := appendError(t, alice, id, "\nc\nd"); code != "too_many_lines" {
+		t.Fatalf("got %q", code)
+	}
+	if m, _ := findMessage(id); m.Content != "a\nb" {
+		t.Fatalf("content %q after rejected append", m.Content)
+	}
+}
+
+func TestAppendLengthCap(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+	id := startStream(t, alice, "room", "a")
+	if code := appendError(t, alice, id, strings.Repeat("x", maxFrameBytes)); code != "too_long" {
+		t.Fatalf("got %q", code)
+	}
+}
+
+func TestAppendRequiresMembershipAndOpenSession(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+	id := startStream(t, alice, "room", "a")
+
+	sessMu.Lock()
+	sessions["room"].Archived = true
+	sessMu.Unlock()
+	if code := appendError(t, alice, id, "b"); code != "session_archived" {
+		t.Fatalf("archived: got %q", code)
+	}
+
+	sessMu.Lock()
+	sessions["room"].Archived = false
+	delete(members["room"], "alice")
+	sessMu.Unlock()
+	if code := appendError(t, alice, id, "b"); code != "not_member" {
+		t.Fatalf("removed member: got %q", code)
+	}
+	alice.noEvent("content_delta", 50*time.Millisecond)
+}
+
+func withPipeline(t *testing.T, names string) {
+	t.Helper()
+	p := contentPipeline
+	contentPipeline = buildPipeline(names)
+	t.Cleanup(func() { contentPipeline = p })
+}
+
+func appendChunk(t *testing.T, c *testClient, id int64, chunk string) ContentDelta {
+	t.Helper()
+	c.send(map[string]interface{}{"type": "append", "id": id, "chunk": chunk})
+	var d ContentDelta
+	_ = json.Unmarshal(c.event("content_delta"), &d)
+	return d
+}
+
+func TestAppendKeepsWhitespaceBetweenChunks(t *testing.T) {
+	resetState(t)
+	withPipeline(t, "trim,sanitize")
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+	id := startStream(t, alice, "room", "hello ")
+
+	if d := appendChunk(t, alice, id, "world"); d.Chunk != " world" {
+		t.Fatalf("delta %+v", d)
+	}
+	if m, _ := findMessage(id); m.Content != "hello world" {
+		t.Fatalf("content %q", m.Content)
+	}
+}
+
+func TestAppendExpandsShortcodeAcrossChunks(t *testing.T) {
+	resetState(t)
+	withPipeline(t, "emoji")
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+	id := startStream(t, alice, "room", "hi :smi")
+
+	// 替换改变了已下发的内容，因此下发完整内容
+	if d := appendChunk(t, alice, id, "le:"); d.Content != "hi 😄" {
+		t.Fatalf("delta %+v", d)
+	}
+	if m, _ := findMessage(id); m.Content != "hi 😄" {
+		t.Fatalf("content %q", m.Content)
+	}
+}
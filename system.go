@@ -0,0 +1,53 @@
+package main
+
+import "time"
+
+// 系统消息的发送者
+const systemSender = "system"
+
+// 连接成功后仅发给本人的欢迎语（如群规、使用提示），为空时不发送；可按语言覆盖，见 welcomeText
+var welcomeMessage = envString("WELCOME_MESSAGE", "")
+
+// 向会话写入并广播一条系统消息，内容由文案键生成并按接收者语言本地化，readers 中的用户视为已读（如操作者本人）
+func postSystemMessage(sessionID, key string, args []string, readers ...string) Message {
+	return postSystem(Message{
+		From:     systemSender,
+		To:       sessionID,
+		Content:  translate(defaultLang, key, args...),
+		Key:      key,
+		Args:     args,
+		IsSystem: true,
+		ReadBy:   readers,
+	})
+}
+
+// 存储并广播构造好的系统消息
+func postSystem(msg Message) Message {
+	msgMu.Lock()
+	msg.ID = allocMessageIDLocked()
+	msg.Timestamp = time.Now()
+	msg.UpdatedAt = msg.Timestamp
+	messages = append(messages, msg)
+	trackMessagesLocked(msg)
+	persistLocked(msg)
+	msgMu.Unlock()
+	flushStore()
+
+	touchSession(msg.To, msg.Content, msg.Timestamp)
+	broadcast(msg)
+	return msg
+}
+
+// 私下向刚连接的用户发送欢迎语，不写入存储也不广播
+func sendWelcome(user *User) {
+	text := welcomeText(user.lang)
+	if text == "" {
+		return
+	}
+	user.enqueue(Event{Type: "welcome", Data: Message{
+		From:      systemSender,
+		Content:   text,
+		Timestamp: time.Now(),
+		IsSystem:  true,
+	}})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// 话题回复是否同时出现在主频道（历史记录与会话预览）
+var threadRepliesInChannel = envBool("THREAD_REPLIES_IN_CHANNEL", true)
+
+// 消息是否因属于话题而不出现在主频道
+func hiddenFromChannel(msg Message) bool {
+	return msg.ThreadID != 0 && !threadRepliesInChannel
+}
+
+// 解析话题根消息：根消息须在同一会话且对发送者可见，回复话题内的消息时归入其所属话题
+func threadRoot(sessionID string, id int64, sender string) (int64, bool) {
+	root, ok := findMessage(id)
+	if !ok || root.To != sessionID || !visibleTo(root, sender) {
+		return 0, false
+	}
+	if root.ThreadID != 0 {
+		return root.ThreadID, true
+	}
+	return root.ID, true
+}
+
+// 获取话题：GET /api/thread?root_id=&user=，返回根消息及全部回复（按 ID 升序）
+func threadHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("user")
+	rootID, err := strconv.ParseInt(r.URL.Query().Get("root_id"), 10, 64)
+	if err != nil || username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	root, ok := findMessage(rootID)
+	if !ok || root.ThreadID != 0 || !canView(root, username) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	res := []Message{root}
+	msgMu.Lock()
+	for _, msg := range messages {
+		if msg.ThreadID == rootID && !msg.Deleted && visibleTo(msg, username) {
+			res = append(res, msg)
+		}
+	}
+	msgMu.Unlock()
+	withReadState(res)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"html"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// 内容转换器：按顺序作用于消息内容
+type ContentTransformer interface {
+	Name() string
+	Transform(content string) string
+}
+
+// 以函数实现的转换器
+type transformFunc struct {
+	name string
+	fn   func(string) string
+}
+
+func (t transformFunc) Name() string                    { return t.name }
+func (t transformFunc) Transform(content string) string { return t.fn(content) }
+
+// 内置转换器，可通过 CONTENT_TRANSFORMS 按名称启用并指定顺序
+var builtinTransformers = map[string]ContentTransformer{
+	"trim":     transformFunc{name: "trim", fn: strings.TrimSpace},
+	"sanitize": transformFunc{name: "sanitize", fn: sanitizeContent},
+	"emoji":    transformFunc{name: "emoji", fn: expandEmoji},
+}
+
+// 聊天消息使用的转换管道，默认不做任何转换
+var contentPipeline = buildPipeline(envString("CONTENT_TRANSFORMS", ""))
+
+// 按逗号分隔的名称构建转换管道，未知名称会被忽略并记录日志
+func buildPipeline(names string) []ContentTransformer {
+	var pipeline []ContentTransformer
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		t, ok := builtinTransformers[name]
+		if !ok {
+			log.Printf("未知的内容转换器: %s", name)
+			continue
+		}
+		pipeline = append(pipeline, t)
+	}
+	return pipeline
+}
+
+// 依次应用转换管道
+func applyTransforms(pipeline []ContentTransformer, content string) string {
+	for _, t := range pipeline {
+		content = t.Transform(content)
+	}
+	return content
+}
+
+// 内容最大字符数（用于清理）
+const maxSanitizedLength = 1000
+
+// 清理内容：去除控制字符、转义 HTML 并限制长度
+func sanitizeContent(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' {
+			return -1
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > maxSanitizedLength {
+		s = string(r[:maxSanitizedLength])
+	}
+	return html.EscapeString(s)
+}
+
+// 表情短码
+var emojiCodes = strings.NewReplacer(
+	":smile:", "😄",
+	":laugh:", "😂",
+	":heart:", "❤️",
+	":thumbsup:", "👍",
+	":fire:", "🔥",
+	":ok:", "👌",
+)
+
+// 展开表情短码
+func expandEmoji(s string) string {
+	return emojiCodes.Replace(s)
+}
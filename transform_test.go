@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func pipelineNames(p []ContentTransformer) []string {
+	var names []string
+	for _, t := range p {
+		names = append(names, t.Name())
+	}
+	return names
+}
+
+func TestBuildPipelineByName(t *testing.T) {
+	cases := []struct {
+		names string
+		want  []string
+	}{
+		{"", nil},
+		{"emoji", []string{"emoji"}},
+		{"sanitize, emoji", []string{"sanitize", "emoji"}},
+		{"emoji,sanitize", []string{"emoji", "sanitize"}},
+		{"trim,bogus,emoji", []string{"trim", "emoji"}}, // 未知名称被忽略
+		{",,", nil},
+	}
+	for _, c := range cases {
+		got := pipelineNames(buildPipeline(c.names))
+		if !equalStrings(got, c.want) {
+			t.Errorf("buildPipeline(%q) = %v, want %v", c.names, got, c.want)
+		}
+	}
+}
+
+// 转换按配置顺序依次执行：先转义再展开与先展开再转义结果不同
+func TestPipelineAppliesInOrder(t *testing.T) {
+	tag := transformFunc{name: "tag", fn: func(s string) string { return "<" + s + ">" }}
+	sanitize := builtinTransformers["sanitize"]
+
+	if got := applyTransforms([]ContentTransformer{tag, sanitize}, "hi"); got != "&lt;hi&gt;" {
+		t.Fatalf("tag then sanitize: %q", got)
+	}
+	if got := applyTransforms([]ContentTransformer{sanitize, tag}, "hi"); got != "<hi>" {
+		t.Fatalf("sanitize then tag: %q", got)
+	}
+	if got := applyTransforms(buildPipeline("trim,emoji"), "  :fire: "); got != "🔥" {
+		t.Fatalf("trim,emoji: %q", got)
+	}
+}
+
+// 未启用的转换器不生效
+func TestDisabledTransformerSkipped(t *testing.T) {
+	if got := applyTransforms(buildPipeline("trim"), " :fire: "); got != ":fire:" {
+		t.Fatalf("emoji applied while disabled: %q", got)
+	}
+	if got := applyTransforms(nil, " <b> "); got != " <b> " {
+		t.Fatalf("empty pipeline changed content: %q", got)
+	}
+}
+
+// 接收消息时使用配置的管道
+func TestMessageUsesPipeline(t *testing.T) {
+	resetState(t)
+	withPipeline(t, "emoji")
+	newTestSession("room", true, "alice")
+	alice := connect(t, "alice")
+	alice.send(map[string]string{"to": "room", "content": "nice :thumbsup:"})
+	if got := alice.message(); got.Content != "nice 👍" {
+		t.Fatalf("content %q", got.Content)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// 消息内容翻译接口，部署方可对接外部翻译服务
+type Translator interface {
+	Translate(text, targetLang string) (string, error)
+}
+
+// 默认翻译器：原样返回
+type noopTranslator struct{}
+
+func (noopTranslator) Translate(text, _ string) (string, error) { return text, nil }
+
+// 当前使用的翻译器
+var contentTranslator Translator = noopTranslator{}
+
+// 翻译缓存的条目上限，写满后整体清空
+var translationCacheSize = envInt("TRANSLATION_CACHE", 1000)
+
+// 按消息与目标语言缓存译文，原文变化（如流式追加）后缓存失效
+type translationKey struct {
+	id   int64
+	lang string
+}
+
+type translationEntry struct {
+	source string
+	text   string
+}
+
+var (
+	translations  = make(map[translationKey]translationEntry)
+	translationMu sync.Mutex
+)
+
+// 翻译结果事件内容
+type TranslationEvent struct {
+	ID      int64  `json:"id"`
+	Lang    string `json:"lang"`
+	Content string `json:"content"`
+}
+
+// 翻译消息内容，不修改存储的原文
+func translateContent(msg Message, lang string) (string, error) {
+	key := translationKey{id: msg.ID, lang: lang}
+	translationMu.Lock()
+	entry, ok := translations[key]
+	translationMu.Unlock()
+	if ok && entry.source == msg.Content {
+		return entry.text, nil
+	}
+
+	text, err := contentTranslator.Translate(msg.Content, lang)
+	if err != nil {
+		return "", err
+	}
+	translationMu.Lock()
+	if translationCacheSize > 0 && len(translations) >= translationCacheSize {
+		translations = make(map[translationKey]translationEntry)
+	}
+	translations[key] = translationEntry{source: msg.Content, text: text}
+	translationMu.Unlock()
+	return text, nil
+}
+
+// 处理 translate 帧：翻译可能较慢，在后台完成后推送结果
+func handleTranslate(user *User, data []byte) {
+	var req struct {
+		ID         int64  `json:"id"`
+		TargetLang string `json:"target_lang"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil || req.ID == 0 {
+		sendError(user, "bad_frame", "无法解析的翻译请求")
+		return
+	}
+	lang := strings.ToLower(strings.TrimSpace(req.TargetLang))
+	if lang == "" {
+		lang = user.lang
+	}
+	msg, ok := findMessage(req.ID)
+	if !ok || !visibleTo(msg, user.Username) || !ensureMember(msg.To, user.Username) {
+		sendError(user, "not_found", "消息不存在")
+		return
+	}
+
+	go func() {
+		defer recoverLog("消息翻译")
+		text, err := translateContent(msg, lang)
+		if err != nil {
+			sendError(user, "translate_failed", "翻译失败")
+			return
+		}
+		user.enqueue(Event{Type: "translation", Data: TranslationEvent{ID: msg.ID, Lang: lang, Content: text}})
+	}()
+}
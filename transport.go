@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// 连接传输层抽象：真实连接使用 WebSocket，测试或嵌入场景可替换为内存实现
+type Transport interface {
+	Send(v interface{}) error            // 编码并发送一帧
+	Receive() ([]byte, error)            // 读取一帧原始数据
+	Close(code int, reason string) error // 携带关闭码与原因断开，重复调用无副作用
+}
+
+// 支持协商线路编码的传输层，握手后、开始收发前调用
+type encodingNegotiator interface {
+	SetEncoding(name string) bool // 返回是否支持该编码
+}
+
+// 基于 WebSocket 的传输层
+type wsTransport struct {
+	ws      *websocket.Conn
+	once    sync.Once
+	msgpack bool // 已协商 MessagePack：以二进制帧收发
+}
+
+func (t *wsTransport) SetEncoding(name string) bool {
+	switch name {
+	case encodingJSON:
+		t.msgpack = false
+	case encodingMsgpack:
+		t.msgpack = true
+	default:
+		return false
+	}
+	return true
+}
+
+func (t *wsTransport) Send(v interface{}) error {
+	if !t.msgpack {
+		return websocket.JSON.Send(t.ws, v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	packed, err := jsonToMsgpack(data)
+	if err != nil {
+		return err
+	}
+	return websocket.Message.Send(t.ws, packed)
+}
+
+// 接收帧时可能出现的错误
+var (
+	errUnsupportedFrame = errors.New("unsupported frame type")        // 未协商编码的二进制帧，连接仍可继续使用
+	errBadFragmentation = errors.New("invalid message fragmentation") // 分片顺序错误，属于协议错误
+	errFrameTooLarge    = errors.New("message too large")             // 超过 maxFrameBytes，在读取负载前拒绝
+)
+
+// 单条消息（含全部分片）的最大字节数，0 表示不限制
+var maxFrameBytes = envInt("MAX_FRAME_BYTES", 256<<10)
+
+// 读取一条完整消息：按 FIN 位拼接分片帧，控制帧由底层处理；MessagePack 消息转为 JSON 返回
+func (t *wsTransport) Receive() ([]byte, error) {
+	var buf []byte
+	started, packed := false, false
+	for {
+		frame, err := t.ws.NewFrameReader()
+		if err != nil {
+			return nil, err
+		}
+		opcode := frame.PayloadType() // 处理前的原始类型，用于识别续帧
+		fin := frameFin(frame)
+		frame, err = t.ws.HandleFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil {
+			continue // ping/pong 已处理
+		}
+
+		switch {
+		case opcode == websocket.TextFrame && !started:
+			started = true
+		case opcode == websocket.ContinuationFrame && started:
+		case opcode == websocket.BinaryFrame && !started && t.msgpack:
+			started, packed = true, true
+		case opcode == websocket.BinaryFrame && !started:
+			_, _ = io.Copy(io.Discard, frame)
+			return nil, errUnsupportedFrame
+		default:
+			_, _ = io.Copy(io.Discard, frame)
+			return nil, errBadFragmentation
+		}
+
+		// 按帧头声明的长度提前拒绝，读取时再限长，避免为超大帧分配内存
+		var r io.Reader = frame
+		remaining := maxFrameBytes - len(buf)
+		if maxFrameBytes > 0 {
+			if frame.Len() > remaining {
+				return nil, errFrameTooLarge
+			}
+			r = io.LimitReader(frame, int64(remaining)+1)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if maxFrameBytes > 0 && len(data) > remaining {
+			return nil, errFrameTooLarge
+		}
+		buf = append(buf, data...)
+		if !fin {
+			continue
+		}
+		// 内容是否为合法 JSON 由帧分发逻辑校验，非法时报告 bad_frame；MessagePack 无法转换时同样原样返回
+		if packed {
+			if text, err := msgpackToJSON(buf); err == nil {
+				return text, nil
+			}
+		}
+		return buf, nil
+	}
+}
+
+// 读取帧头的 FIN 位，判断是否为消息的最后一个分片；无法取得帧头时视为单帧消息
+func frameFin(frame interface{ HeaderReader() io.Reader }) bool {
+	header, ok := frame.HeaderReader().(interface{ Bytes() []byte })
+	if !ok {
+		return true
+	}
+	b := header.Bytes()
+	return len(b) == 0 || b[0]&0x80 != 0
+}
+
+func (t *wsTransport) Close(code int, reason string) error {
+	var err error
+	t.once.Do(func() {
+		// 先以事件告知重连建议，客户端据此退避
+		if hint, ok := reconnectHint(code); ok {
+			_ = t.Send(Event{Type: "reconnect", Data: hint})
+		}
+		if len(reason) > maxCloseReason {
+			reason = reason[:maxCloseReason]
+		}
+		payload := make([]byte, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		copy(payload[2:], reason)
+
+		// 标准库未暴露带原因的关闭接口，借助 PayloadType 直接写出关闭帧
+		t.ws.PayloadType = websocket.CloseFrame
+		_, _ = t.ws.Write(payload)
+		err = t.ws.Close()
+	})
+	return err
+}
+
+// 传输层已关闭
+var errTransportClosed = errors.New("transport closed")
+
+// 内存传输层：一对互联的端点，帧以 JSON 编码在两端之间传递
+type pipeTransport struct {
+	in     chan []byte
+	peer   *pipeTransport
+	closed chan struct{}
+	once   sync.Once
+
+	// 关闭时记录的关闭码与原因
+	CloseCode   int
+	CloseReason string
+}
+
+// 创建一对互联的内存传输层，分别作为服务端与客户端使用
+func newPipeTransport() (server, client *pipeTransport) {
+	server = &pipeTransport{in: make(chan []byte, 64), closed: make(chan struct{})}
+	client = &pipeTransport{in: make(chan []byte, 64), closed: make(chan struct{})}
+	server.peer, client.peer = client, server
+	return server, client
+}
+
+func (t *pipeTransport) Send(v interface{}) error {
+	var data []byte
+	switch v := v.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	select {
+	case t.peer.in <- data:
+		return nil
+	case <-t.closed:
+		return errTransportClosed
+	case <-t.peer.closed:
+		return errTransportClosed
+	}
+}
+
+func (t *pipeTransport) Receive() ([]byte, error) {
+	select {
+	case data := <-t.in:
+		return data, nil
+	case <-t.closed:
+		return nil, errTransportClosed
+	case <-t.peer.closed:
+		return nil, errTransportClosed
+	}
+}
+
+func (t *pipeTransport) Close(code int, reason string) error {
+	t.once.Do(func() {
+		t.CloseCode, t.CloseReason = code, reason
+		close(t.closed)
+	})
+	return nil
+}
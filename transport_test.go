@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// 单次 Receive 的结果
+type received struct {
+	data []byte
+	err  error
+}
+
+// 启动 WebSocket 服务端，把 wsTransport 读到的每条消息写入通道；返回原始 TCP 客户端连接
+func dialRawWS(t *testing.T) (net.Conn, <-chan received) {
+	t.Helper()
+	results := make(chan received, 16)
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		tr := &wsTransport{ws: ws}
+		for {
+			data, err := tr.Receive()
+			results <- received{data, err}
+			if err != nil && err != errUnsupportedFrame {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\nOrigin: http://localhost\r\n\r\n", key)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade failed: %v %v", resp, err)
+	}
+	return conn, results
+}
+
+// 写出一个带掩码的客户端帧
+func writeFrame(t *testing.T, conn net.Conn, fin bool, opcode byte, payload string) {
+	t.Helper()
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	if len(payload) > 125 {
+		t.Fatal("test frames must be short")
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{b0, 0x80 | byte(len(payload)), mask[0], mask[1], mask[2], mask[3]}
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func nextReceived(t *testing.T, ch <-chan received) received {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+	return received{}
+}
+
+func TestReceiveReassemblesFragmentsByFin(t *testing.T) {
+	conn, results := dialRawWS(t)
+	writeFrame(t, conn, false, websocket.TextFrame, `{"type":`)
+	writeFrame(t, conn, false, websocket.ContinuationFrame, `"typing",`)
+	writeFrame(t, conn, true, websocket.ContinuationFrame, `"session_id":"s"}`)
+
+	r := nextReceived(t, results)
+	if r.err != nil || string(r.data) != `{"type":"typing","session_id":"s"}` {
+		t.Fatalf("got %q, %v", r.data, r.err)
+	}
+}
+
+func TestReceiveMalformedFrameDoesNotWaitForContinuation(t *testing.T) {
+	conn, results := dialRawWS(t)
+	writeFrame(t, conn, true, websocket.TextFrame, `{"type":"mess`)
+	if r := nextReceived(t, results); r.err != nil || string(r.data) != `{"type":"mess` {
+		t.Fatalf("malformed frame: got %q, %v", r.data, r.err)
+	}
+
+	// 下一条文本帧应作为新消息读取，而不是分片错误
+	writeFrame(t, conn, true, websocket.TextFrame, `{"type":"ping"}`)
+	if r := nextReceived(t, results); r.err != nil || string(r.data) != `{"type":"ping"}` {
+		t.Fatalf("next frame: got %q, %v", r.data, r.err)
+	}
+}
+
+func TestReceiveRejectsContinuationWithoutStart(t *testing.T) {
+	conn, results := dialRawWS(t)
+	writeFrame(t, conn, true, websocket.ContinuationFrame, `"x"}`)
+	if r := nextReceived(t, results); r.err != errBadFragmentation {
+		t.Fatalf("got %q, %v; want errBadFragmentation", r.data, r.err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// 正在输入状态的有效期，超时未续期则自动发出 typing_stopped
+var typingTTL = envDuration("TYPING_TTL", 5*time.Second)
+
+// 输入状态事件内容
+type TypingEvent struct {
+	SessionID string `json:"session_id"`
+	User      string `json:"user"`
+}
+
+var (
+	typingTimers = make(map[userSessionKey]*time.Timer)
+	typingMu     sync.Mutex
+)
+
+// 向会话内除指定用户外的在线成员推送事件
+func broadcastEventExcept(sessionID string, ev Event, except string) {
+	recipients := sessionMembers(sessionID)
+	userMu.Lock()
+	defer userMu.Unlock()
+	for name, conns := range users {
+		if name == except || !recipients[name] {
+			continue
+		}
+		for u := range conns {
+			u.enqueue(ev)
+		}
+	}
+}
+
+// 处理 typing 帧：首次输入时广播，续期时仅重置计时器
+func handleTyping(user *User, data []byte) {
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的输入状态")
+		return
+	}
+	if !ensureMember(req.SessionID, user.Username) {
+		sendError(user, "not_member", "你不是该会话成员")
+		return
+	}
+
+	key := userSessionKey{user: user.Username, session: req.SessionID}
+	typingMu.Lock()
+	t, renewing := typingTimers[key]
+	if renewing {
+		t.Reset(typingTTL)
+	} else {
+		typingTimers[key] = time.AfterFunc(typingTTL, func() { stopTyping(key) })
+	}
+	typingMu.Unlock()
+
+	if !renewing {
+		broadcastEventExcept(req.SessionID, Event{Type: "typing", Data: TypingEvent{SessionID: req.SessionID, User: user.Username}}, user.Username)
+	}
+}
+
+// 结束输入状态并通知其他成员，状态不存在时不做处理
+func stopTyping(key userSessionKey) {
+	typingMu.Lock()
+	t, ok := typingTimers[key]
+	if ok {
+		t.Stop()
+		delete(typingTimers, key)
+	}
+	typingMu.Unlock()
+
+	if ok {
+		broadcastEventExcept(key.session, Event{Type: "typing_stopped", Data: TypingEvent{SessionID: key.session, User: key.user}}, key.user)
+	}
+}
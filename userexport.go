@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// 用户数据导出（用于数据主体访问请求）
+type UserExport struct {
+	User         string           `json:"user"`
+	ExportedAt   time.Time        `json:"exported_at"`
+	Profile      *Profile         `json:"profile,omitempty"`
+	Messages     []Message        `json:"messages"`  // 用户发送的全部未删除消息
+	Sessions     []string         `json:"sessions"`  // 已加入的会话
+	LastRead     map[string]int64 `json:"last_read"` // 各会话最后已读的消息 ID
+	Drafts       []Draft          `json:"drafts"`
+	NotifyPrefs  NotifyPrefs      `json:"notify_prefs"`
+	SessionOrder []string         `json:"session_order,omitempty"`
+	Stats        *UserStats       `json:"stats,omitempty"`
+	ReportsFiled []Report         `json:"reports_filed"`
+}
+
+// 汇总用户在各处保存的数据
+func exportUser(username string) UserExport {
+	exp := UserExport{
+		User:         username,
+		ExportedAt:   time.Now(),
+		Messages:     make([]Message, 0),
+		Sessions:     make([]string, 0),
+		LastRead:     make(map[string]int64),
+		Drafts:       userDrafts(username),
+		NotifyPrefs:  getPrefs(username),
+		ReportsFiled: make([]Report, 0),
+	}
+	if p, ok := userProfile(username); ok {
+		exp.Profile = &p
+	}
+
+	joined := userSessions(username)
+	for id := range joined {
+		exp.Sessions = append(exp.Sessions, id)
+	}
+	sort.Strings(exp.Sessions)
+
+	msgMu.Lock()
+	for _, msg := range messages {
+		if msg.Deleted {
+			continue
+		}
+		if msg.From == username {
+			exp.Messages = append(exp.Messages, msg)
+		}
+	}
+	msgMu.Unlock()
+	for sid, id := range userReadMarks(username) {
+		exp.LastRead[sid] = id
+	}
+
+	orderMu.Lock()
+	exp.SessionOrder = append([]string(nil), sessionOrders[username]...)
+	orderMu.Unlock()
+
+	statsMu.Lock()
+	if st, ok := userStats[username]; ok {
+		c := copyStatsLocked(st)
+		exp.Stats = &c
+	}
+	statsMu.Unlock()
+
+	reportMu.Lock()
+	for _, r := range reports {
+		if r.Reporter == username {
+			exp.ReportsFiled = append(exp.ReportsFiled, r)
+		}
+	}
+	reportMu.Unlock()
+	return exp
+}
+
+// 导出用户全部数据（管理员）：GET /api/admin/user-export?user=
+func userExportHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	username := r.URL.Query().Get("user")
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	exp := exportUser(username)
+	audit(adminActor(r), "user_export", username, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="user-export.json"`)
+	_ = json.NewEncoder(w).Encode(exp)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserExportContainsMessagesAndProfile(t *testing.T) {
+	resetState(t)
+	t.Setenv("ADMIN_TOKEN", "secret")
+	newTestSession("room", true, "alice", "bob")
+	mine := seedMessage(Message{From: "alice", To: "room", Content: "from alice"})
+	seedMessage(Message{From: "bob", To: "room", Content: "from bob"})
+	connect(t, "alice")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/user-export?user=alice", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	userExportHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export returned %d", w.Code)
+	}
+	var exp UserExport
+	if err := json.Unmarshal(w.Body.Bytes(), &exp); err != nil {
+		t.Fatal(err)
+	}
+	if exp.User != "alice" || exp.Profile == nil || exp.Profile.Username != "alice" {
+		t.Fatalf("profile %+v", exp.Profile)
+	}
+	if len(exp.Messages) != 1 || exp.Messages[0].ID != mine.ID || exp.Messages[0].Content != "from alice" {
+		t.Fatalf("messages %+v", exp.Messages)
+	}
+	if len(exp.Sessions) != 1 || exp.Sessions[0] != "room" {
+		t.Fatalf("sessions %v", exp.Sessions)
+	}
+}
+
+func TestUserExportRequiresAdmin(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+	w := httptest.NewRecorder()
+	userExportHandler(w, httptest.NewRequest(http.MethodGet, "/api/admin/user-export?user=alice", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got %d without token", w.Code)
+	}
+}
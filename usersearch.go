@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 用户名前缀搜索的返回上限，以及 recent=1 时纳入的最近活跃时间范围
+var (
+	userSearchLimit  = envInt("USER_SEARCH_LIMIT", 10)
+	userSearchRecent = envDuration("USER_SEARCH_RECENT", 7*24*time.Hour)
+)
+
+// 前缀搜索命中的用户
+type UserMatch struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Avatar      string `json:"avatar,omitempty"`
+	Online      bool   `json:"online"`
+}
+
+// 按用户名前缀查找用户（大小写不敏感），在线用户在前，同组内按用户名排序
+func searchUsers(prefix string, recent bool, now time.Time) []UserMatch {
+	prefix = strings.ToLower(prefix)
+	found := make(map[string]UserMatch)
+
+	userMu.Lock()
+	for name, conns := range users {
+		if !strings.HasPrefix(strings.ToLower(name), prefix) {
+			continue
+		}
+		for u := range conns {
+			found[name] = UserMatch{Username: name, DisplayName: u.DisplayName, Avatar: u.Avatar, Online: true}
+			break
+		}
+	}
+	userMu.Unlock()
+
+	if recent {
+		lastSeenMu.Lock()
+		for name, seen := range lastSeen {
+			if _, ok := found[name]; ok || now.Sub(seen) > userSearchRecent {
+				continue
+			}
+			if strings.HasPrefix(strings.ToLower(name), prefix) {
+				found[name] = UserMatch{Username: name}
+			}
+		}
+		lastSeenMu.Unlock()
+	}
+
+	res := make([]UserMatch, 0, len(found))
+	for _, m := range found {
+		if !m.Online {
+			m.DisplayName, m.Avatar = identityResolver.Resolve(m.Username)
+			if !avatarsEnabled {
+				m.Avatar = ""
+			}
+		}
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Online != res[j].Online {
+			return res[i].Online
+		}
+		return res[i].Username < res[j].Username
+	})
+	if userSearchLimit > 0 && len(res) > userSearchLimit {
+		res = res[:userSearchLimit]
+	}
+	return res
+}
+
+// 用户名前缀搜索，用于 @ 提及自动补全：GET /api/users/search?prefix=&recent=1
+func userSearchHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	recent := r.URL.Query().Get("recent") == "1"
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(searchUsers(prefix, recent, time.Now()))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func searchUsersHTTP(t *testing.T, prefix string) []UserMatch {
+	t.Helper()
+	w := httptest.NewRecorder()
+	userSearchHandler(w, httptest.NewRequest(http.MethodGet, "/api/users/search?prefix="+prefix, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("search %q returned %d", prefix, w.Code)
+	}
+	var res []UserMatch
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestUserSearchPrefix(t *testing.T) {
+	resetState(t)
+	connect(t, "Alice")
+	connect(t, "albert")
+	connect(t, "bob")
+
+	got := searchUsersHTTP(t, "AL")
+	if len(got) != 2 || got[0].Username != "Alice" || got[1].Username != "albert" || !got[0].Online {
+		t.Fatalf("prefix al: %+v", got)
+	}
+	if got := searchUsersHTTP(t, "zed"); len(got) != 0 {
+		t.Fatalf("prefix zed: %+v", got)
+	}
+}
+
+func TestUserSearchCap(t *testing.T) {
+	resetState(t)
+	limit := userSearchLimit
+	userSearchLimit = 3
+	t.Cleanup(func() { userSearchLimit = limit })
+	for i := 0; i < 5; i++ {
+		connect(t, fmt.Sprintf("user%d", i))
+	}
+	if got := searchUsersHTTP(t, "user"); len(got) != 3 {
+		t.Fatalf("got %d results, want 3", len(got))
+	}
+}
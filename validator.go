@@ -0,0 +1,14 @@
+package main
+
+// 消息校验接口：在存储前检查消息，返回非空错误时拒绝并把错误信息告知发送者
+type MessageValidator interface {
+	Validate(msg Message, session Session) error
+}
+
+// 默认校验器：不做任何限制
+type noopValidator struct{}
+
+func (noopValidator) Validate(Message, Session) error { return nil }
+
+// 当前使用的消息校验器，部署方可替换为自己的规则（禁止链接、屏蔽词等）
+var messageValidator MessageValidator = noopValidator{}
@@ -0,0 +1,50 @@
+package main
+
+import "encoding/json"
+
+// 判断消息对指定用户是否可见：悄悄话仅发送者与指定接收者可见
+func visibleTo(msg Message, username string) bool {
+	if len(msg.Whisper) == 0 || msg.From == username {
+		return true
+	}
+	for _, name := range msg.Whisper {
+		if name == username {
+			return true
+		}
+	}
+	return false
+}
+
+// 处理 whisper 帧：管理员向群内部分成员发送仅其可见的消息
+func handleWhisper(user *User, data []byte) {
+	var req struct {
+		SessionID string   `json:"session_id"`
+		Content   string   `json:"content"`
+		To        []string `json:"to"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		sendError(user, "bad_frame", "无法解析的悄悄话")
+		return
+	}
+	if !user.verifiedAdmin() {
+		sendError(user, "forbidden", "只有管理员可以发送悄悄话")
+		return
+	}
+
+	// 只保留会话成员作为接收者
+	joined := sessionMembers(req.SessionID)
+	var to []string
+	seen := make(map[string]bool)
+	for _, name := range req.To {
+		if joined[name] && name != user.Username && !seen[name] {
+			seen[name] = true
+			to = append(to, name)
+		}
+	}
+	if len(to) == 0 {
+		sendError(user, "no_recipients", "没有有效的接收者")
+		return
+	}
+
+	handleMessage(user, Message{To: req.SessionID, Content: req.Content, Whisper: to})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func historyFor(t *testing.T, viewer string) []Message {
+	t.Helper()
+	w := httptest.NewRecorder()
+	messagesHandler(w, httptest.NewRequest(http.MethodGet, "/api/messages?session_id=room&legacy=1&user="+viewer, nil))
+	var list []Message
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	return list
+}
+
+// 悄悄话只投递给指定成员与发送者，其他成员既收不到也查不到
+func TestWhisperHiddenFromNonRecipients(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "mod", "bob", "carol")
+	bob := connect(t, "bob") // 在启用挑战认证之前连接
+	carol := connect(t, "carol")
+	mod := connectAdmin(t, "mod")
+
+	mod.send(map[string]interface{}{"type": "whisper", "session_id": "room", "content": "final warning", "to": []string{"bob"}})
+	if got := bob.message(); got.Content != "final warning" || len(got.Whisper) != 1 {
+		t.Fatalf("bob got %+v", got)
+	}
+	if got := mod.message(); got.Content != "final warning" {
+		t.Fatalf("sender echo %+v", got)
+	}
+	carol.noEvent("", 100*time.Millisecond) // 聊天消息帧没有 type 字段
+
+	if list := historyFor(t, "bob"); len(list) != 1 {
+		t.Fatalf("bob history %+v", list)
+	}
+	if list := historyFor(t, "mod"); len(list) != 1 {
+		t.Fatalf("sender history %+v", list)
+	}
+	if list := historyFor(t, "carol"); len(list) != 0 {
+		t.Fatalf("carol history %+v", list)
+	}
+	if list := historyFor(t, ""); len(list) != 0 {
+		t.Fatalf("anonymous history %+v", list)
+	}
+}
+
+func TestWhisperRequiresAdmin(t *testing.T) {
+	resetState(t)
+	newTestSession("room", true, "alice", "bob")
+	alice := connect(t, "alice")
+	alice.send(map[string]interface{}{"type": "whisper", "session_id": "room", "content": "psst", "to": []string{"bob"}})
+	var info ErrorInfo
+	_ = json.Unmarshal(alice.event("error"), &info)
+	if info.Code != "forbidden" {
+		t.Fatalf("got %+v", info)
+	}
+}